@@ -0,0 +1,137 @@
+// Package acapi provides a client for the HTTP API exposed by AssettoServer.
+package acapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"agones/types"
+)
+
+// InfoResponse is the response of the /INFO endpoint.
+type InfoResponse struct {
+	Cars         []string `json:"cars"`         // Car models available on the server
+	Clients      int      `json:"clients"`      // Number of connected clients
+	MaxClients   int      `json:"maxclients"`   // Maximum number of clients
+	Name         string   `json:"name"`         // Server name
+	Session      int      `json:"session"`      // Current session type (see SessionTypeName)
+	SessionTypes []int    `json:"sessiontypes"` // Configured session types
+	Durations    []int    `json:"durations"`    // Configured session durations
+	TimeLeft     int      `json:"timeleft"`     // Time left in the current session (seconds)
+	Track        string   `json:"track"`        // Track name, including the layout
+	Port         int      `json:"port"`         // UDP port
+	TPort        int      `json:"tport"`        // TCP port
+	CPort        int      `json:"cport"`        // HTTP port
+	Pass         bool     `json:"pass"`         // Indicates if the server is password protected
+	PoweredBy    string   `json:"poweredBy"`    // Server version
+}
+
+// DetailCar is an entry list slot in the /api/details response.
+type DetailCar struct {
+	Model        string `json:"Model"`
+	Skin         string `json:"Skin"`
+	DriverName   string `json:"DriverName"`
+	DriverTeam   string `json:"DriverTeam"`
+	DriverNation string `json:"DriverNation"`
+	IsConnected  bool   `json:"IsConnected"`
+	IsEntryList  bool   `json:"IsEntryList"`
+	ID           string `json:"ID"` // Hashed GUID of the driver
+}
+
+// DetailResponse is the response of the /api/details endpoint.
+type DetailResponse struct {
+	InfoResponse
+	Players struct {
+		Cars []DetailCar `json:"Cars"`
+	} `json:"players"`
+	TrackBase          string  `json:"trackBase"`          // Track name without the layout
+	Frequency          int     `json:"frequency"`          // Server refresh rate (Hz)
+	AmbientTemperature float64 `json:"ambientTemperature"` // Air temperature (Celsius)
+	RoadTemperature    float64 `json:"roadTemperature"`    // Track temperature (Celsius)
+	Grip               float64 `json:"grip"`               // Track grip percentage
+	CurrentWeatherID   string  `json:"currentWeatherId"`   // Current weather
+}
+
+// ConnectedDrivers returns the names of the connected drivers.
+func (d *DetailResponse) ConnectedDrivers() []string {
+	var names []string
+	for _, car := range d.Players.Cars {
+		if car.IsConnected && car.DriverName != "" {
+			names = append(names, car.DriverName)
+		}
+	}
+	return names
+}
+
+// SessionTypeName converts an AssettoServer session type to a session type constant.
+func SessionTypeName(sessionType int) string {
+	switch sessionType {
+	case 1:
+		return types.SessionTypePractice
+	case 2:
+		return types.SessionTypeQualifying
+	case 3:
+		return types.SessionTypeRace
+	default:
+		return types.SessionTypeUnknown
+	}
+}
+
+// Client queries the HTTP API of a local AssettoServer.
+type Client struct {
+	baseURL string
+	http    *http.Client
+}
+
+// NewClient creates a Client for the server listening at baseURL (e.g. http://127.0.0.1:8081).
+func NewClient(baseURL string, timeout time.Duration) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		http:    &http.Client{Timeout: timeout},
+	}
+}
+
+// Info queries the /INFO endpoint.
+func (c *Client) Info(ctx context.Context) (*InfoResponse, error) {
+	var info InfoResponse
+	if err := c.get(ctx, "/INFO", &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// Details queries the /api/details endpoint.
+func (c *Client) Details(ctx context.Context) (*DetailResponse, error) {
+	var details DetailResponse
+	if err := c.get(ctx, "/api/details", &details); err != nil {
+		return nil, err
+	}
+	return &details, nil
+}
+
+// get performs a GET request and decodes the JSON response into v.
+func (c *Client) get(ctx context.Context, path string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s request failed: %v", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", path, resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode %s response: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package acapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"agones/types"
+)
+
+// newTestServer serves the captured /api/details response, and its /INFO subset.
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	for _, path := range []string{"/api/details", "/INFO"} {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			http.ServeFile(w, r, "testdata/details.json")
+		})
+	}
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDetails(t *testing.T) {
+	client := NewClient(newTestServer(t).URL+"/", time.Second)
+
+	details, err := client.Details(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if details.Clients != 2 || details.MaxClients != 24 || details.TimeLeft != 1642 {
+		t.Errorf("clients %d/%d, time left %d", details.Clients, details.MaxClients, details.TimeLeft)
+	}
+	if details.Track != "ks_nordschleife-endurance" || details.TrackBase != "ks_nordschleife" {
+		t.Errorf("track %q, base %q", details.Track, details.TrackBase)
+	}
+	if details.Grip != 98.5 || details.RoadTemperature != 31.5 || details.Frequency != 18 {
+		t.Errorf("grip %v, road %v, frequency %d", details.Grip, details.RoadTemperature, details.Frequency)
+	}
+	if got := SessionTypeName(details.Session); got != types.SessionTypeRace {
+		t.Errorf("session type %q, want race", got)
+	}
+	if got, want := details.ConnectedDrivers(), []string{"Bob", "Alice"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ConnectedDrivers = %v, want %v", got, want)
+	}
+}
+
+func TestInfo(t *testing.T) {
+	client := NewClient(newTestServer(t).URL, time.Second)
+
+	info, err := client.Info(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != "Agones Test Server | Miata Cup" || len(info.Cars) != 2 || info.CPort != 8081 {
+		t.Errorf("info = %+v", info)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/INFO" {
+			w.Write([]byte("not json"))
+			return
+		}
+		http.Error(w, "starting", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	client := NewClient(server.URL, time.Second)
+
+	if _, err := client.Details(context.Background()); err == nil {
+		t.Error("Details succeeded on a 503")
+	}
+	if _, err := client.Info(context.Background()); err == nil {
+		t.Error("Info succeeded on an invalid body")
+	}
+}
@@ -0,0 +1,352 @@
+// Package admin implements the authenticated admin HTTP API of the wrapper, used by
+// operators to inspect and control a GameServer without exec'ing into the pod.
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"agones/config"
+	"agones/console"
+	"agones/scheduler"
+	"agones/session"
+	"agones/types"
+	"agones/utils"
+)
+
+const (
+	commandTimeout = 5 * time.Second // Maximum time a command waits for the rate limit
+	maxBodySize    = 64 * 1024       // Maximum size of a request body
+)
+
+// Console sends the admin commands to the game server, implemented by console.Console.
+type Console interface {
+	Kick(ctx context.Context, player, reason string) error
+	Ban(ctx context.Context, player, reason string) error
+	Broadcast(ctx context.Context, message string) error
+	NextSession(ctx context.Context) error
+	RestartSession(ctx context.Context) error
+}
+
+// Config configures the admin API.
+type Config struct {
+	Token     string                  // Bearer token required on every request
+	Config    *types.Config           // Effective configuration exposed by /config
+	State     *types.ServerState      // Server state exposed by /state and /players
+	Sessions  *session.SessionManager // Sessions exposed by /sessions
+	Console   Console                 // Console the admin commands are sent through, nil if unavailable
+	Scheduler *scheduler.Scheduler    // Scheduler whose jobs are exposed by /schedule, nil if unavailable
+
+	// Shutdown shuts the server down gracefully.
+	Shutdown func(reason string)
+	// Drain starts draining the server before shutting it down, nil if unsupported.
+	Drain func(reason string) error
+}
+
+// Server serves the admin API.
+type Server struct {
+	config Config
+	mux    *http.ServeMux
+}
+
+// New creates the admin API server.
+func New(config Config) *Server {
+	a := &Server{
+		config: config,
+		mux:    http.NewServeMux(),
+	}
+	a.Handle(http.MethodGet, "/state", a.handleState)
+	a.Handle(http.MethodGet, "/players", a.handlePlayers)
+	a.Handle(http.MethodGet, "/sessions", a.handleSessions)
+	a.Handle(http.MethodGet, "/config", a.handleConfig)
+	a.Handle(http.MethodPost, "/shutdown", a.handleShutdown)
+	a.Handle(http.MethodPost, "/drain", a.handleDrain)
+	a.Handle(http.MethodGet, "/schedule", a.handleSchedule)
+	a.Handle(http.MethodPost, "/players/kick", a.handleKick)
+	a.Handle(http.MethodPost, "/players/ban", a.handleBan)
+	a.Handle(http.MethodPost, "/broadcast", a.handleBroadcast)
+	a.Handle(http.MethodPost, "/session/next", a.handleCommand(func(ctx context.Context, c Console) error {
+		return c.NextSession(ctx)
+	}))
+	a.Handle(http.MethodPost, "/session/restart", a.handleCommand(func(ctx context.Context, c Console) error {
+		return c.RestartSession(ctx)
+	}))
+	return a
+}
+
+// Handle registers an endpoint answering to method only.
+func (a *Server) Handle(method, path string, handler http.HandlerFunc) {
+	a.mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		handler(w, r)
+	})
+}
+
+// ServeHTTP authenticates the request and dispatches it to the endpoint.
+func (a *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found || a.config.Token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(a.config.Token)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	a.mux.ServeHTTP(w, r)
+}
+
+// stateView is the JSON view of the server state.
+type stateView struct {
+	ServerID        string         `json:"server_id"`
+	ServerName      string         `json:"server_name"`
+	ServerType      string         `json:"server_type"`
+	Ready           bool           `json:"ready"`
+	Allocated       bool           `json:"allocated"`
+	ShuttingDown    bool           `json:"shutting_down"`
+	Draining        bool           `json:"draining"`
+	Unhealthy       bool           `json:"unhealthy"`
+	Players         int            `json:"players"`
+	MaxClients      int            `json:"max_clients"`
+	Track           string         `json:"track"`
+	Layout          string         `json:"layout"`
+	Session         *types.Session `json:"session"`
+	SessionTimeLeft int            `json:"session_time_left"`
+	TrackTemp       float64        `json:"track_temp"`
+	AirTemp         float64        `json:"air_temp"`
+	TrackGrip       float64        `json:"track_grip"`
+	TickRate        float64        `json:"tick_rate"`
+	CarModels       []string       `json:"car_models"`
+	ActiveCars      map[string]int `json:"active_cars"`
+	ProcessPID      int            `json:"process_pid"`
+	ProcessStarted  time.Time      `json:"process_started"`
+	LastExitCode    int            `json:"last_exit_code"`
+	LastPing        time.Time      `json:"last_ping"`
+	LastOutput      time.Time      `json:"last_output"`
+}
+
+// handleState returns a snapshot of the server state.
+func (a *Server) handleState(w http.ResponseWriter, _ *http.Request) {
+	state := a.config.State
+	state.RLock()
+	view := stateView{
+		ServerID:        state.ServerID,
+		ServerName:      state.ServerName,
+		ServerType:      state.ServerType,
+		Ready:           state.Ready,
+		Allocated:       state.Allocated,
+		ShuttingDown:    state.ShuttingDown,
+		Draining:        state.Draining,
+		Unhealthy:       state.Unhealthy,
+		Players:         state.Players,
+		MaxClients:      state.MaxClients,
+		Track:           state.CurrentTrack,
+		Layout:          state.CurrentLayout,
+		SessionTimeLeft: state.SessionTimeLeft,
+		TrackTemp:       state.TrackTemp,
+		AirTemp:         state.AirTemp,
+		TrackGrip:       state.TrackGrip,
+		TickRate:        state.TickRate,
+		CarModels:       append([]string(nil), state.CarModels...),
+		ActiveCars:      make(map[string]int, len(state.ActiveCars)),
+		ProcessPID:      state.ProcessPID,
+		ProcessStarted:  state.ProcessStarted,
+		LastExitCode:    state.LastExitCode,
+		LastPing:        state.LastPing,
+		LastOutput:      state.LastOutput,
+	}
+	if state.CurrentSession != nil {
+		view.Session = state.CurrentSession.Clone()
+	}
+	for model, count := range state.ActiveCars {
+		view.ActiveCars[model] = count
+	}
+	state.RUnlock()
+
+	writeJSON(w, http.StatusOK, view)
+}
+
+// handlePlayers returns the connected players, sorted by name.
+func (a *Server) handlePlayers(w http.ResponseWriter, _ *http.Request) {
+	state := a.config.State
+	state.RLock()
+	players := make([]types.Player, 0, len(state.ConnectedPlayers))
+	for _, player := range state.ConnectedPlayers {
+		players = append(players, *player)
+	}
+	state.RUnlock()
+
+	sort.Slice(players, func(i, j int) bool { return players[i].Name < players[j].Name })
+	writeJSON(w, http.StatusOK, players)
+}
+
+// handleSessions returns the current session with its leaderboard and the session history.
+func (a *Server) handleSessions(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, struct {
+		Current     *types.Session      `json:"current"`
+		Leaderboard []types.Participant `json:"leaderboard"`
+		History     []*types.Session    `json:"history"`
+	}{
+		Current:     a.config.Sessions.GetCurrentSession(),
+		Leaderboard: a.config.Sessions.Leaderboard(),
+		History:     a.config.Sessions.GetSessionHistory(),
+	})
+}
+
+// handleConfig returns the effective configuration.
+func (a *Server) handleConfig(w http.ResponseWriter, _ *http.Request) {
+	data, err := config.JSON(a.config.Config)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// handleSchedule returns the scheduled jobs with their status.
+func (a *Server) handleSchedule(w http.ResponseWriter, _ *http.Request) {
+	jobs := []scheduler.JobStatus{}
+	if a.config.Scheduler != nil {
+		jobs = a.config.Scheduler.Status()
+	}
+	writeJSON(w, http.StatusOK, jobs)
+}
+
+// handleShutdown shuts the server down.
+func (a *Server) handleShutdown(w http.ResponseWriter, r *http.Request) {
+	utils.LogEvent("ADMIN", "Shutdown requested through the admin API by %s", r.RemoteAddr)
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "shutting down"})
+
+	// Shut down once the response is sent
+	go a.config.Shutdown("admin API request")
+}
+
+// handleDrain starts draining the server.
+func (a *Server) handleDrain(w http.ResponseWriter, r *http.Request) {
+	if a.config.Drain == nil {
+		writeError(w, http.StatusNotImplemented, "draining is not supported")
+		return
+	}
+	utils.LogEvent("ADMIN", "Drain requested through the admin API by %s", r.RemoteAddr)
+	if err := a.config.Drain("admin API request"); err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "draining"})
+}
+
+// playerRequest is the body of the kick and ban requests.
+type playerRequest struct {
+	Player string `json:"player"` // Name or Steam ID of the player
+	Reason string `json:"reason"`
+}
+
+// handleKick kicks a player.
+func (a *Server) handleKick(w http.ResponseWriter, r *http.Request) {
+	var req playerRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if req.Player == "" {
+		writeError(w, http.StatusBadRequest, "player must be set")
+		return
+	}
+	a.handleCommand(func(ctx context.Context, c Console) error {
+		return c.Kick(ctx, req.Player, req.Reason)
+	})(w, r)
+}
+
+// handleBan bans a player.
+func (a *Server) handleBan(w http.ResponseWriter, r *http.Request) {
+	var req playerRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if req.Player == "" {
+		writeError(w, http.StatusBadRequest, "player must be set")
+		return
+	}
+	a.handleCommand(func(ctx context.Context, c Console) error {
+		return c.Ban(ctx, req.Player, req.Reason)
+	})(w, r)
+}
+
+// handleBroadcast sends a chat message to every player.
+func (a *Server) handleBroadcast(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Message string `json:"message"`
+	}
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if req.Message == "" {
+		writeError(w, http.StatusBadRequest, "message must be set")
+		return
+	}
+	a.handleCommand(func(ctx context.Context, c Console) error {
+		return c.Broadcast(ctx, req.Message)
+	})(w, r)
+}
+
+// handleCommand returns a handler sending a command through the console.
+func (a *Server) handleCommand(send func(ctx context.Context, c Console) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if a.config.Console == nil {
+			writeError(w, http.StatusNotImplemented, "server commands are not supported")
+			return
+		}
+		utils.LogEvent("ADMIN", "Command %s requested through the admin API by %s", r.URL.Path, r.RemoteAddr)
+
+		ctx, cancel := context.WithTimeout(r.Context(), commandTimeout)
+		defer cancel()
+		switch err := send(ctx, a.config.Console); {
+		case errors.Is(err, console.ErrNotRunning), errors.Is(err, console.ErrBusy):
+			writeError(w, http.StatusServiceUnavailable, err.Error())
+		case errors.Is(err, context.DeadlineExceeded):
+			writeError(w, http.StatusGatewayTimeout, err.Error())
+		case err != nil:
+			writeError(w, http.StatusBadRequest, err.Error())
+		default:
+			writeJSON(w, http.StatusOK, map[string]string{"status": "sent"})
+		}
+	}
+}
+
+// readJSON decodes the JSON request body into value, writing an error response on failure.
+func readJSON(w http.ResponseWriter, r *http.Request, value interface{}) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(value); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// writeJSON writes value as the JSON response.
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// writeError writes an error as the JSON response.
+func writeError(w http.ResponseWriter, status int, message string) {
+	data, _ := json.Marshal(map[string]string{"error": message})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
@@ -0,0 +1,196 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"agones/console"
+	"agones/session"
+	"agones/types"
+)
+
+// fakeConsole records the commands it is sent, failing them with err.
+type fakeConsole struct {
+	err      error
+	commands []string
+}
+
+func (c *fakeConsole) send(command string) error {
+	c.commands = append(c.commands, command)
+	return c.err
+}
+
+func (c *fakeConsole) Kick(_ context.Context, player, reason string) error {
+	return c.send(fmt.Sprintf("kick %s %s", player, reason))
+}
+
+func (c *fakeConsole) Ban(_ context.Context, player, reason string) error {
+	return c.send(fmt.Sprintf("ban %s %s", player, reason))
+}
+
+func (c *fakeConsole) Broadcast(_ context.Context, message string) error {
+	return c.send("say " + message)
+}
+
+func (c *fakeConsole) NextSession(context.Context) error {
+	return c.send("next_session")
+}
+
+func (c *fakeConsole) RestartSession(context.Context) error {
+	return c.send("restart_session")
+}
+
+func newTestServer(token string, commands Console) *Server {
+	return New(Config{
+		Token: token,
+		State: &types.ServerState{
+			ServerID:     "gs-1",
+			Players:      2,
+			CurrentTrack: "monza",
+			ConnectedPlayers: map[string]*types.Player{
+				"76561198000000002": {Name: "Bob", SteamID: "76561198000000002"},
+				"76561198000000001": {Name: "Alice", SteamID: "76561198000000001"},
+			},
+		},
+		Sessions: session.NewSessionManager(10),
+		Console:  commands,
+	})
+}
+
+// request serves a request authenticated with authorization, returning the response.
+func request(a *Server, method, path, authorization, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if authorization != "" {
+		r.Header.Set("Authorization", authorization)
+	}
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, r)
+	return w
+}
+
+func TestAuthentication(t *testing.T) {
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+		want          int
+	}{
+		{"valid token", "s3cret", "Bearer s3cret", http.StatusOK},
+		{"missing token", "s3cret", "", http.StatusUnauthorized},
+		{"wrong token", "s3cret", "Bearer guess", http.StatusUnauthorized},
+		{"missing scheme", "s3cret", "s3cret", http.StatusUnauthorized},
+		{"other scheme", "s3cret", "Basic s3cret", http.StatusUnauthorized},
+		{"no configured token", "", "Bearer ", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		w := request(newTestServer(tt.token, nil), http.MethodGet, "/state", tt.authorization, "")
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.want)
+		}
+		if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: no WWW-Authenticate header", tt.name)
+		}
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	a := newTestServer("s3cret", &fakeConsole{})
+	for path, method := range map[string]string{
+		"/state":        http.MethodPost,
+		"/players/kick": http.MethodGet,
+		"/shutdown":     http.MethodGet,
+	} {
+		w := request(a, method, path, "Bearer s3cret", "")
+		if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") == "" {
+			t.Errorf("%s %s: status %d, Allow %q, want 405 with the allowed method", method, path, w.Code, w.Header().Get("Allow"))
+		}
+	}
+}
+
+func TestState(t *testing.T) {
+	w := request(newTestServer("s3cret", nil), http.MethodGet, "/state", "Bearer s3cret", "")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("status %d, content type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	var view stateView
+	if err := json.Unmarshal(w.Body.Bytes(), &view); err != nil {
+		t.Fatal(err)
+	}
+	if view.ServerID != "gs-1" || view.Players != 2 || view.Track != "monza" || view.Session != nil {
+		t.Errorf("state %+v", view)
+	}
+}
+
+func TestPlayers(t *testing.T) {
+	w := request(newTestServer("s3cret", nil), http.MethodGet, "/players", "Bearer s3cret", "")
+	var players []types.Player
+	if err := json.Unmarshal(w.Body.Bytes(), &players); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, player := range players {
+		names = append(names, player.Name)
+	}
+	if want := []string{"Alice", "Bob"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("players %q, want %q sorted by name", names, want)
+	}
+}
+
+func TestPlayerCommands(t *testing.T) {
+	tests := []struct {
+		path    string
+		body    string
+		want    int
+		command string
+	}{
+		{"/players/kick", `{"player": "Bob", "reason": "spam"}`, http.StatusOK, "kick Bob spam"},
+		{"/players/ban", `{"player": "76561198000000002"}`, http.StatusOK, "ban 76561198000000002 "},
+		{"/players/kick", `{"player": ""}`, http.StatusBadRequest, ""},
+		{"/players/ban", `{}`, http.StatusBadRequest, ""},
+		{"/players/kick", `{"player": "Bob", "duration": 60}`, http.StatusBadRequest, ""},
+		{"/players/ban", `{"player": `, http.StatusBadRequest, ""},
+		{"/broadcast", `{"message": ""}`, http.StatusBadRequest, ""},
+		{"/broadcast", `{"message": "hello"}`, http.StatusOK, "say hello"},
+	}
+	for _, tt := range tests {
+		commands := &fakeConsole{}
+		w := request(newTestServer("s3cret", commands), http.MethodPost, tt.path, "Bearer s3cret", tt.body)
+		if w.Code != tt.want {
+			t.Errorf("%s %s: status %d, want %d", tt.path, tt.body, w.Code, tt.want)
+		}
+		if got := strings.Join(commands.commands, "|"); got != tt.command {
+			t.Errorf("%s %s: sent %q, want %q", tt.path, tt.body, got, tt.command)
+		}
+	}
+}
+
+func TestCommandErrors(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{nil, http.StatusOK},
+		{console.ErrNotRunning, http.StatusServiceUnavailable},
+		{console.ErrBusy, http.StatusServiceUnavailable},
+		{fmt.Errorf("failed to send the command: %w", context.DeadlineExceeded), http.StatusGatewayTimeout},
+		{console.ErrRejected, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		w := request(newTestServer("s3cret", &fakeConsole{err: tt.err}), http.MethodPost, "/session/next", "Bearer s3cret", "")
+		if w.Code != tt.want {
+			t.Errorf("error %v: status %d, want %d", tt.err, w.Code, tt.want)
+		}
+	}
+
+	// Without RCON, the commands are unavailable
+	w := request(newTestServer("s3cret", nil), http.MethodPost, "/session/restart", "Bearer s3cret", "")
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("without a console: status %d, want %d", w.Code, http.StatusNotImplemented)
+	}
+}
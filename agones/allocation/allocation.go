@@ -0,0 +1,283 @@
+// Package allocation follows the Agones state of the GameServer and applies the configured
+// actions when it gets allocated.
+package allocation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	coresdk "agones.dev/agones/pkg/sdk"
+	sdk "agones.dev/agones/sdks/go"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"agones/metrics"
+	"agones/types"
+	"agones/utils"
+)
+
+// Action is an action applied when the GameServer gets allocated.
+type Action string
+
+// Supported allocation actions.
+const (
+	ActionSessionTimer Action = "session_timer" // Shut down once the session limit has elapsed
+	ActionMetadata     Action = "metadata"      // Write the allocation labels and annotations to a file
+	ActionConfigure    Action = "configure"     // Reconfigure the server from the allocation labels and annotations
+)
+
+// errReserve explains why reserve is not an allocation action.
+var errReserve = errors.New("reserve is not an allocation action: a reserved GameServer returns to Ready, " +
+	"and can be allocated again with players on it, once the reservation expires")
+
+// ParseActions parses a comma-separated list of actions.
+func ParseActions(list string) ([]Action, error) {
+	var actions []Action
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		switch a := Action(name); a {
+		case ActionSessionTimer, ActionMetadata, ActionConfigure:
+			actions = append(actions, a)
+		case "reserve":
+			return nil, errReserve
+		default:
+			return nil, fmt.Errorf("unknown allocation action %q", name)
+		}
+	}
+	return actions, nil
+}
+
+// Agones GameServer states.
+const (
+	StateReady     = "Ready"
+	StateAllocated = "Allocated"
+	StateReserved  = "Reserved"
+	StateShutdown  = "Shutdown"
+)
+
+// Config configures the allocation actions.
+type Config struct {
+	Actions      []Action      // Actions applied on allocation
+	SessionLimit time.Duration // Time after allocation before the session_timer action shuts down
+	MetadataFile string        // File written by the metadata action
+
+	// Configure is called by the configure action with the labels and annotations of the GameServer.
+	Configure func(labels, annotations map[string]string) error
+	// Allocated is called on every allocation, after the actions, with the labels and
+	// annotations of the GameServer. It may be nil.
+	Allocated func(labels, annotations map[string]string)
+}
+
+// Metadata is the allocation metadata written by the metadata action.
+type Metadata struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	AllocatedAt time.Time         `json:"allocated_at"`
+}
+
+// Watcher follows the GameServer state through WatchGameServer.
+type Watcher struct {
+	s        *sdk.SDK
+	state    *types.ServerState
+	config   Config
+	shutdown func(reason string) // Shuts the server down gracefully
+
+	mu        sync.Mutex
+	lastState string
+	metadata  Metadata    // Allocation labels and annotations of the last allocation
+	timer     *time.Timer // Session timer, nil when not running
+}
+
+// sdkMetadataPrefix prefixes the labels and annotations set through the SDK, which are not
+// part of the allocation metadata.
+const sdkMetadataPrefix = "agones.dev/sdk-"
+
+// NewWatcher creates a Watcher applying config on allocation.
+func NewWatcher(s *sdk.SDK, state *types.ServerState, config Config, shutdown func(reason string)) *Watcher {
+	return &Watcher{
+		s:        s,
+		state:    state,
+		config:   config,
+		shutdown: shutdown,
+	}
+}
+
+// Start subscribes to the GameServer updates.
+func (w *Watcher) Start() error {
+	return w.s.WatchGameServer(w.update)
+}
+
+// Stop stops the session timer.
+func (w *Watcher) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stopTimer()
+}
+
+// update handles a GameServer update.
+func (w *Watcher) update(gs *coresdk.GameServer) {
+	current := gs.GetStatus().GetState()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	previous := w.lastState
+	if current == previous {
+		// A re-allocation leaves the GameServer Allocated with new metadata
+		if current == StateAllocated && w.metadataChanged(gs) {
+			utils.LogEvent("ALLOCATED", "GameServer re-allocated with new metadata")
+			w.onAllocated(gs, true)
+		}
+		return
+	}
+	w.lastState = current
+
+	utils.LogSDK("GameServer state changed from %s to %s", stateName(previous), current)
+
+	allocated := current == StateAllocated
+	w.state.Lock()
+	w.state.Allocated = allocated
+	labels := prometheus.Labels{
+		"server_id":   w.state.ServerID,
+		"server_name": w.state.ServerName,
+		"server_type": w.state.ServerType,
+	}
+	w.state.Unlock()
+
+	if value, ok := gaugeValue(current); ok {
+		metrics.ServerStateGauge.With(labels).Set(value)
+	}
+
+	switch {
+	case allocated:
+		utils.LogEvent("ALLOCATED", "GameServer allocated")
+		w.metadataChanged(gs)
+		w.onAllocated(gs, false)
+	case previous == StateAllocated:
+		w.stopTimer()
+	}
+}
+
+// metadataChanged records the allocation metadata of gs and returns whether it changed.
+// Must be called with w.mu held.
+func (w *Watcher) metadataChanged(gs *coresdk.GameServer) bool {
+	meta := gs.GetObjectMeta()
+	metadata := Metadata{
+		Labels:      allocationMetadata(meta.GetLabels()),
+		Annotations: allocationMetadata(meta.GetAnnotations()),
+	}
+	if reflect.DeepEqual(metadata, w.metadata) {
+		return false
+	}
+	w.metadata = metadata
+	return true
+}
+
+// allocationMetadata returns a copy of values without the ones set through the SDK.
+func allocationMetadata(values map[string]string) map[string]string {
+	filtered := make(map[string]string, len(values))
+	for key, value := range values {
+		if !strings.HasPrefix(key, sdkMetadataPrefix) {
+			filtered[key] = value
+		}
+	}
+	return filtered
+}
+
+// onAllocated applies the configured actions. A re-allocation only applies the actions
+// depending on the metadata, the session timer of the allocation is kept.
+// Must be called with w.mu held.
+func (w *Watcher) onAllocated(gs *coresdk.GameServer, reallocated bool) {
+	for _, action := range w.config.Actions {
+		var err error
+		switch action {
+		case ActionSessionTimer:
+			if !reallocated {
+				w.startTimer()
+			}
+		case ActionMetadata:
+			err = writeMetadata(w.config.MetadataFile, gs)
+		case ActionConfigure:
+			if w.config.Configure != nil {
+				err = w.config.Configure(gs.GetObjectMeta().GetLabels(), gs.GetObjectMeta().GetAnnotations())
+			}
+		}
+		if err != nil {
+			utils.LogError("Allocation action %s failed: %v", action, err)
+		}
+	}
+	if w.config.Allocated != nil {
+		w.config.Allocated(gs.GetObjectMeta().GetLabels(), gs.GetObjectMeta().GetAnnotations())
+	}
+}
+
+// startTimer starts the session timer. Must be called with w.mu held.
+func (w *Watcher) startTimer() {
+	w.stopTimer()
+	limit := w.config.SessionLimit
+	utils.LogSDK("Session limit of %v started", limit)
+	w.timer = time.AfterFunc(limit, func() {
+		utils.LogSDK("Session limit of %v reached", limit)
+		w.shutdown("session limit reached")
+	})
+}
+
+// stopTimer stops the session timer if running. Must be called with w.mu held.
+func (w *Watcher) stopTimer() {
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+}
+
+// writeMetadata writes the labels and annotations of the GameServer to path.
+func writeMetadata(path string, gs *coresdk.GameServer) error {
+	meta := gs.GetObjectMeta()
+	data, err := json.MarshalIndent(Metadata{
+		Labels:      meta.GetLabels(),
+		Annotations: meta.GetAnnotations(),
+		AllocatedAt: time.Now(),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write atomically so that readers never see a partial file
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// gaugeValue converts an Agones state to a ServerStateGauge value.
+func gaugeValue(state string) (float64, bool) {
+	switch state {
+	case StateReady:
+		return types.ServerStateReady, true
+	case StateAllocated:
+		return types.ServerStateAllocated, true
+	case StateReserved:
+		return types.ServerStateReserved, true
+	case StateShutdown:
+		return types.ServerStateShutdown, true
+	default:
+		return 0, false
+	}
+}
+
+// stateName returns the name of a state for logging.
+func stateName(state string) string {
+	if state == "" {
+		return "unknown"
+	}
+	return state
+}
@@ -0,0 +1,80 @@
+package allocation
+
+import (
+	"testing"
+	"time"
+
+	coresdk "agones.dev/agones/pkg/sdk"
+
+	"agones/types"
+)
+
+func gameServer(state string, labels, annotations map[string]string) *coresdk.GameServer {
+	return &coresdk.GameServer{
+		ObjectMeta: &coresdk.GameServer_ObjectMeta{Labels: labels, Annotations: annotations},
+		Status:     &coresdk.GameServer_Status{State: state},
+	}
+}
+
+func TestConfigureOnReallocation(t *testing.T) {
+	var configured []string
+	w := NewWatcher(nil, &types.ServerState{}, Config{
+		Actions: []Action{ActionConfigure},
+		Configure: func(_, annotations map[string]string) error {
+			configured = append(configured, annotations["track"])
+			return nil
+		},
+	}, func(string) {})
+
+	updates := []*coresdk.GameServer{
+		gameServer(StateReady, nil, nil),
+		gameServer(StateAllocated, map[string]string{"mode": "race"}, map[string]string{"track": "monza"}),
+		// Annotations set by the wrapper through the SDK are not a re-allocation
+		gameServer(StateAllocated, map[string]string{"mode": "race"}, map[string]string{"track": "monza", "agones.dev/sdk-players": "3"}),
+		gameServer(StateAllocated, map[string]string{"mode": "race"}, map[string]string{"track": "spa", "agones.dev/sdk-players": "3"}),
+		gameServer(StateReady, nil, nil),
+		gameServer(StateAllocated, map[string]string{"mode": "race"}, map[string]string{"track": "spa"}),
+	}
+	for _, gs := range updates {
+		w.update(gs)
+	}
+
+	want := []string{"monza", "spa", "spa"}
+	if len(configured) != len(want) {
+		t.Fatalf("configured = %q, want %q", configured, want)
+	}
+	for i := range want {
+		if configured[i] != want[i] {
+			t.Errorf("configured = %q, want %q", configured, want)
+			break
+		}
+	}
+}
+
+func TestReserveAction(t *testing.T) {
+	if _, err := ParseActions("session_timer,reserve"); err == nil {
+		t.Error("ParseActions accepted the reserve action")
+	}
+}
+
+func TestAllocationKeptOnUpdate(t *testing.T) {
+	state := &types.ServerState{}
+	w := NewWatcher(nil, state, Config{
+		Actions:      []Action{ActionSessionTimer},
+		SessionLimit: time.Hour,
+	}, func(string) {})
+	defer w.Stop()
+
+	// The wrapper updates the GameServer it allocated, e.g. with the draining annotation,
+	// without releasing it or stopping its session timer
+	for _, gs := range []*coresdk.GameServer{
+		gameServer(StateReady, nil, nil),
+		gameServer(StateAllocated, nil, nil),
+		gameServer(StateAllocated, nil, map[string]string{"agones.dev/sdk-draining": "true"}),
+	} {
+		w.update(gs)
+	}
+	if !state.Allocated || w.timer == nil {
+		t.Errorf("Allocated = %v, session timer running = %v, want true, true", state.Allocated, w.timer != nil)
+	}
+}
@@ -0,0 +1,429 @@
+// Package config loads the wrapper configuration from defaults, a JSON/YAML file,
+// environment variables and command line flags.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"agones/allocation"
+	"agones/monitoring"
+	"agones/parser"
+	"agones/scheduler"
+	"agones/session"
+	"agones/supervisor"
+	"agones/types"
+	"agones/utils"
+	"agones/webhook"
+)
+
+// EnvPrefix is the prefix of the environment variables overriding configuration keys,
+// e.g. WRAPPER_METRICS_PORT for metrics_port.
+const EnvPrefix = "WRAPPER_"
+
+// EnvConfigFile names the environment variable holding the configuration file path.
+const EnvConfigFile = EnvPrefix + "CONFIG"
+
+// option describes the command line flag of a configuration key.
+type option struct {
+	key   string // Configuration key, as in the yaml tag of types.Config
+	flag  string // Flag name
+	usage string // Flag usage
+}
+
+// options lists the configuration keys settable from the command line.
+var options = []option{
+	{"server_script", "i", "Path to server start script"},
+	{"server_args", "args", "Arguments for the server"},
+	{"shutdown_timeout", "shutdown-timeout", "Shutdown timeout"},
+	{"drain_timeout", "drain-timeout", "Maximum time players are given to finish their race before a shutdown (0 disables draining)"},
+	{"termination_grace", "termination-grace", "terminationGracePeriodSeconds of the pod, bounding the drain on SIGTERM"},
+	{"health_check_rate", "health-check-rate", "Interval between Agones health pings"},
+	{"metrics_interval", "metrics-interval", "Interval between GameServer metrics updates"},
+	{"metrics_port", "metrics-port", "Port of the Prometheus metrics endpoint"},
+	{"health_port", "health-port", "Port of the HTTP health and config endpoints"},
+	{"debug", "debug", "Enable debug logs"},
+	{"log_format", "log-format", "Format of the wrapper logs: text or json"},
+	{"input_format", "input-format", "Format of the server output: text or clef (Serilog compact JSON)"},
+	{"rules_file", "rules", "Path to a YAML/JSON file with output rules overriding the built-in ones"},
+	{"restart_policy", "restart-policy", "Action when the server process exits: restart or shutdown"},
+	{"max_restarts", "max-restarts", "Maximum number of consecutive server process restarts"},
+	{"restart_backoff", "restart-backoff", "Delay before the first restart, doubled on every attempt"},
+	{"restart_max_backoff", "restart-max-backoff", "Maximum delay between restarts"},
+	{"liveness_output_window", "liveness-output-window", "Maximum time without server output before health pings stop (0 disables)"},
+	{"liveness_update_loop", "liveness-update-loop", "Require the server update loop to have started for health pings"},
+	{"liveness_startup_grace", "liveness-startup-grace", "Time after a server start during which only the process liveness probe applies"},
+	{"liveness_info_url", "liveness-info-url", "URL of the server /INFO endpoint probed before health pings (empty disables)"},
+	{"server_api_url", "server-api-url", "Base URL of the server HTTP API used to reconcile state, e.g. http://127.0.0.1:8081 (empty disables)"},
+	{"server_api_interval", "server-api-interval", "Interval between server HTTP API polls"},
+	{"player_tracking", "player-tracking", "Report players through Agones alpha player tracking"},
+	{"agones_counters", "agones-counters", "Maintain the Agones players and free_slots counters and the cars list"},
+	{"allocation_actions", "allocation-actions", "Comma-separated actions applied when the GameServer is allocated: session_timer, metadata, configure"},
+	{"allocation_limit", "allocation-limit", "Time after allocation before the session_timer action shuts the server down"},
+	{"allocation_metadata", "allocation-metadata", "File the metadata action writes the allocation labels and annotations to"},
+	{"idle_action", "idle-action", "Action applied to an allocated server left without players: ready or shutdown"},
+	{"idle_never_joined", "idle-never-joined", "Time after allocation without any player joining before the idle action (0 disables)"},
+	{"idle_empty", "idle-empty", "Time without players after the last one left before the idle action (0 disables)"},
+	{"session_end_policy", "session-end-policy", "Action when a session ends: shutdown, continue, shutdown-after-race, shutdown-after-n-sessions or shutdown-after-wall-clock"},
+	{"session_end_count", "session-end-count", "Number of completed sessions before shutting down with shutdown-after-n-sessions"},
+	{"session_end_after", "session-end-after", "Running time after which the next session end shuts down with shutdown-after-wall-clock"},
+	{"session_store", "session-store", "JSON-lines file the completed sessions are persisted to and reloaded from (empty disables)"},
+	{"results_dir", "results-dir", "Directory the JSON and CSV results of each session are written to (empty disables)"},
+	{"results_webhook", "results-webhook", "URL the JSON results of each session are posted to (empty disables)"},
+	{"moderation_webhook", "moderation-webhook", "URL each moderation event (kick, ban, refused connection) is posted to (empty disables)"},
+	{"webhooks", "webhooks", "Endpoints separated by semicolons, each a URL optionally followed by the events it receives: ready, player_join, session_change, shutdown (empty disables)"},
+	{"webhook_secret", "webhook-secret", "Key of the HMAC-SHA256 signature of the webhook events, preferably set through WRAPPER_WEBHOOK_SECRET"},
+	{"config_templates", "config-templates", "Directory of server configuration templates (*.tmpl) rendered by the configure allocation action"},
+	{"config_render_dir", "config-render-dir", "Directory the server configuration is rendered to, passed to the start script as AC_RENDERED_CONFIG"},
+	{"admin_port", "admin-port", "Port of the authenticated admin API (0 disables)"},
+	{"admin_token", "admin-token", "Bearer token required by the admin API, preferably set through WRAPPER_ADMIN_TOKEN"},
+	{"rcon_port", "rcon-port", "Port of the server RCON listener (RconPort of extra_cfg.yml) the admin commands are sent to (0 disables)"},
+	{"rcon_password", "rcon-password", "Admin password of the server (ADMIN_PASSWORD of server_cfg.ini) authenticating RCON, preferably set through WRAPPER_RCON_PASSWORD"},
+	{"schedule", "schedule", "Scheduled jobs separated by semicolons, each a cron expression followed by broadcast <message>, restart or drain"},
+	{"entry_list", "entry-list", "Path to the server entry_list.ini, used for the player capacity when the server API is unavailable"},
+}
+
+// Default returns the default configuration.
+func Default() *types.Config {
+	return &types.Config{
+		ServerScript:         "./start-server.sh",
+		ShutdownTimeout:      8 * time.Second,
+		DrainTimeout:         5 * time.Minute,
+		TerminationGrace:     30 * time.Second,
+		HealthCheckRate:      2 * time.Second,
+		MetricsInterval:      30 * time.Second,
+		MetricsPort:          9090,
+		HealthPort:           9001,
+		LogFormat:            utils.LogOutputText,
+		InputFormat:          string(parser.FormatText),
+		RestartPolicy:        string(supervisor.PolicyRestart),
+		MaxRestarts:          3,
+		RestartBackoff:       5 * time.Second,
+		RestartMaxBackoff:    time.Minute,
+		LivenessUpdateLoop:   true,
+		LivenessStartupGrace: 30 * time.Second,
+		ServerAPIInterval:    15 * time.Second,
+		EntryList:            "/app/AssettoServer/cfg/entry_list.ini",
+		ConfigRenderDir:      "/tmp/rendered-config",
+		IdleAction:           string(monitoring.IdleActionShutdown),
+		SessionEndPolicy:     string(session.EndPolicyShutdown),
+	}
+}
+
+// Load builds the effective configuration from, in increasing order of precedence, the
+// defaults, the configuration file, the WRAPPER_* environment variables and the flags
+// explicitly set in args. The flags are registered on fs, which must not be parsed yet.
+func Load(fs *flag.FlagSet, args []string) (*types.Config, error) {
+	flagged := Default()
+	for _, opt := range options {
+		if err := bindFlag(fs, opt, field(flagged, opt.key)); err != nil {
+			return nil, err
+		}
+	}
+	configFile := fs.String("config", os.Getenv(EnvConfigFile), "Path to a YAML/JSON configuration file (env "+EnvConfigFile+")")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	config := Default()
+	if *configFile != "" {
+		if err := loadFile(*configFile, config); err != nil {
+			return nil, err
+		}
+	}
+	if err := loadEnv(config); err != nil {
+		return nil, err
+	}
+
+	// Only the flags given on the command line override the other sources
+	fs.Visit(func(f *flag.Flag) {
+		for _, opt := range options {
+			if opt.flag == f.Name {
+				field(config, opt.key).Set(field(flagged, opt.key))
+			}
+		}
+	})
+
+	if err := Validate(config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Validate checks the consistency of a configuration.
+func Validate(config *types.Config) error {
+	var errs []error
+
+	if config.ServerScript == "" {
+		errs = append(errs, errors.New("server_script must be set"))
+	}
+	for key, port := range map[string]int{"metrics_port": config.MetricsPort, "health_port": config.HealthPort} {
+		if port < 1 || port > 65535 {
+			errs = append(errs, fmt.Errorf("%s %d is not a valid port", key, port))
+		}
+	}
+	if config.MetricsPort == config.HealthPort {
+		errs = append(errs, fmt.Errorf("metrics_port and health_port must differ (%d)", config.MetricsPort))
+	}
+	if config.AdminPort != 0 {
+		switch {
+		case config.AdminPort < 1 || config.AdminPort > 65535:
+			errs = append(errs, fmt.Errorf("admin_port %d is not a valid port", config.AdminPort))
+		case config.AdminPort == config.MetricsPort || config.AdminPort == config.HealthPort:
+			errs = append(errs, fmt.Errorf("admin_port must differ from metrics_port and health_port (%d)", config.AdminPort))
+		}
+		if config.AdminToken == "" {
+			errs = append(errs, errors.New("admin_token must be set when the admin API is enabled"))
+		}
+	}
+	for key, d := range map[string]time.Duration{
+		"health_check_rate":   config.HealthCheckRate,
+		"metrics_interval":    config.MetricsInterval,
+		"restart_backoff":     config.RestartBackoff,
+		"server_api_interval": config.ServerAPIInterval,
+	} {
+		if d <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", key))
+		}
+	}
+	for key, d := range map[string]time.Duration{
+		"shutdown_timeout":       config.ShutdownTimeout,
+		"drain_timeout":          config.DrainTimeout,
+		"termination_grace":      config.TerminationGrace,
+		"restart_max_backoff":    config.RestartMaxBackoff,
+		"liveness_output_window": config.LivenessOutputWindow,
+		"liveness_startup_grace": config.LivenessStartupGrace,
+		"idle_never_joined":      config.IdleNeverJoined,
+		"idle_empty":             config.IdleEmpty,
+	} {
+		if d < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative", key))
+		}
+	}
+	if config.MaxRestarts < 0 {
+		errs = append(errs, errors.New("max_restarts must not be negative"))
+	}
+	if config.LogFormat != utils.LogOutputText && config.LogFormat != utils.LogOutputJSON {
+		errs = append(errs, fmt.Errorf("unknown log_format %q", config.LogFormat))
+	}
+	if _, err := parser.ParseFormat(config.InputFormat); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := supervisor.ParsePolicy(config.RestartPolicy); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := monitoring.ParseIdleAction(config.IdleAction); err != nil {
+		errs = append(errs, err)
+	}
+	switch policy, err := session.ParseEndPolicy(config.SessionEndPolicy); {
+	case err != nil:
+		errs = append(errs, err)
+	case policy == session.EndPolicyAfterSessions && config.SessionEndCount <= 0:
+		errs = append(errs, errors.New("session_end_count must be positive for shutdown-after-n-sessions"))
+	case policy == session.EndPolicyAfterWallClock && config.SessionEndAfter <= 0:
+		errs = append(errs, errors.New("session_end_after must be positive for shutdown-after-wall-clock"))
+	}
+	jobs, err := scheduler.Parse(config.Schedule)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	// Drains and scheduled broadcasts send their commands to the server over RCON
+	if config.RconPort != 0 {
+		if config.RconPort < 1 || config.RconPort > 65535 {
+			errs = append(errs, fmt.Errorf("rcon_port %d is not a valid port", config.RconPort))
+		}
+		if config.RconPassword == "" {
+			errs = append(errs, errors.New("rcon_password must be set when rcon_port is set"))
+		}
+	} else {
+		if config.DrainTimeout > 0 {
+			errs = append(errs, errors.New("rcon_port and rcon_password must be set to drain the server (or drain_timeout set to 0)"))
+		}
+		for _, job := range jobs {
+			if job.Action == scheduler.ActionBroadcast {
+				errs = append(errs, errors.New("rcon_port and rcon_password must be set for the scheduled broadcasts"))
+				break
+			}
+		}
+	}
+	if _, err := webhook.ParseEndpoints(config.Webhooks); err != nil {
+		errs = append(errs, err)
+	}
+	if actions, err := allocation.ParseActions(config.AllocationActions); err != nil {
+		errs = append(errs, err)
+	} else {
+		for _, action := range actions {
+			switch {
+			case action == allocation.ActionSessionTimer && config.AllocationLimit <= 0:
+				errs = append(errs, errors.New("allocation_limit must be positive for the session_timer action"))
+			case action == allocation.ActionMetadata && config.AllocationMetadata == "":
+				errs = append(errs, errors.New("allocation_metadata must be set for the metadata action"))
+			case action == allocation.ActionConfigure && (config.ConfigTemplates == "" || config.ConfigRenderDir == ""):
+				errs = append(errs, errors.New("config_templates and config_render_dir must be set for the configure action"))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// JSON returns the configuration as JSON, with durations rendered as strings (e.g. "30s").
+// Secrets are redacted.
+func JSON(config *types.Config) ([]byte, error) {
+	redacted := *config
+	if redacted.AdminToken != "" {
+		redacted.AdminToken = "REDACTED"
+	}
+	if redacted.WebhookSecret != "" {
+		redacted.WebhookSecret = "REDACTED"
+	}
+	if redacted.RconPassword != "" {
+		redacted.RconPassword = "REDACTED"
+	}
+	// Webhook URLs often carry a token in their path or query
+	redacted.ResultsWebhook = redactURL(redacted.ResultsWebhook)
+	redacted.ModerationWebhook = redactURL(redacted.ModerationWebhook)
+	redacted.Webhooks = redactEndpoints(redacted.Webhooks)
+
+	// The YAML encoder renders durations as strings, unlike the JSON one
+	data, err := yaml.Marshal(&redacted)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]interface{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(values, "", "  ")
+}
+
+// redactURL keeps the scheme and host of a URL, redacting its user, path, query and fragment.
+func redactURL(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "REDACTED"
+	}
+	return u.Scheme + "://" + u.Host + "/REDACTED"
+}
+
+// redactEndpoints redacts the URLs of a list of webhook endpoints, keeping their event types.
+func redactEndpoints(spec string) string {
+	var endpoints []string
+	for _, entry := range strings.FieldsFunc(spec, func(r rune) bool { return r == ';' || r == '\n' }) {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		fields[0] = redactURL(fields[0])
+		endpoints = append(endpoints, strings.Join(fields, " "))
+	}
+	return strings.Join(endpoints, "; ")
+}
+
+// loadFile reads a YAML or JSON configuration file into config.
+func loadFile(path string, config *types.Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	// JSON is a subset of YAML, so both are read by the YAML decoder
+	decoder := yaml.NewDecoder(strings.NewReader(string(data)))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+	return nil
+}
+
+// loadEnv overrides the configuration keys set in the environment.
+func loadEnv(config *types.Config) error {
+	v := reflect.ValueOf(config).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		key := yamlKey(v.Type().Field(i))
+		name := EnvPrefix + strings.ToUpper(key)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := setField(v.Field(i), value); err != nil {
+			return fmt.Errorf("invalid %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// bindFlag registers the flag of a configuration key on the given field.
+func bindFlag(fs *flag.FlagSet, opt option, v reflect.Value) error {
+	switch p := v.Addr().Interface().(type) {
+	case *string:
+		fs.StringVar(p, opt.flag, *p, opt.usage)
+	case *int:
+		fs.IntVar(p, opt.flag, *p, opt.usage)
+	case *bool:
+		fs.BoolVar(p, opt.flag, *p, opt.usage)
+	case *time.Duration:
+		fs.DurationVar(p, opt.flag, *p, opt.usage)
+	default:
+		return fmt.Errorf("unsupported type %s for flag %s", v.Type(), opt.flag)
+	}
+	return nil
+}
+
+// setField parses value into a configuration field.
+func setField(v reflect.Value, value string) error {
+	switch p := v.Addr().Interface().(type) {
+	case *string:
+		*p = value
+	case *int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		*p = n
+	case *bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		*p = b
+	case *time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*p = d
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// field returns the field of config with the given configuration key.
+func field(config *types.Config, key string) reflect.Value {
+	v := reflect.ValueOf(config).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if yamlKey(v.Type().Field(i)) == key {
+			return v.Field(i)
+		}
+	}
+	panic("config: unknown key " + key)
+}
+
+// yamlKey returns the configuration key of a struct field.
+func yamlKey(f reflect.StructField) string {
+	return strings.Split(f.Tag.Get("yaml"), ",")[0]
+}
@@ -0,0 +1,265 @@
+// Package console sends admin commands to the AssettoServer process through its RCON
+// listener, enabled by RconPort in extra_cfg.yml, where they are handled like the admin
+// chat commands.
+package console
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"agones/metrics"
+	"agones/types"
+	"agones/utils"
+)
+
+// ErrNotRunning is returned when a command is sent while no server process is running.
+var ErrNotRunning = errors.New("server process is not running")
+
+// ErrBusy is returned when the commands are not answered by the server as fast as they are sent.
+var ErrBusy = errors.New("server is not answering its commands")
+
+// ErrRejected is returned when the server answered a command without executing it.
+var ErrRejected = errors.New("command rejected by the server")
+
+// queueSize bounds the number of commands waiting for the server to answer the previous ones.
+const queueSize = 16
+
+// Admin commands of the server.
+const (
+	commandKick           = "kick"
+	commandBan            = "ban"
+	commandSay            = "say"
+	commandNextSession    = "next_session"
+	commandRestartSession = "restart_session"
+	commandSet            = "set"
+)
+
+// passwordProperty is the configuration property holding the server password.
+const passwordProperty = "Server.Password"
+
+// rejections are the beginnings of the replies of the server to the commands it did not
+// execute, such as an unknown player or a property that cannot be set.
+var rejections = []string{
+	"Error.",
+	"Could not",
+	"You cannot",
+	"This player is not connected",
+	"No command found",
+	"An error occurred",
+}
+
+// Config configures the RCON connection to the server.
+type Config struct {
+	Address  string // Address of the RCON listener, e.g. 127.0.0.1:9600
+	Password string // Admin password of the server, which authenticates the RCON clients
+}
+
+// Console sends admin commands to the running server process over RCON, one at a time.
+// Commands are rate limited so that a burst of requests cannot flood the server, and at
+// most queueSize commands wait for a server not answering, so that it blocks no caller.
+type Console struct {
+	state   *types.ServerState
+	limiter *utils.RateLimiter
+	config  Config
+
+	waiting chan struct{} // Slots of the commands waiting for the connection
+	turn    chan struct{} // Held by the command using the connection
+	conn    *rconConn     // Connection to the running process, nil if not connected; guarded by turn
+	connRun int           // Process run conn was opened to; guarded by turn
+
+	mu      sync.Mutex
+	running bool
+	run     int // Incremented on every process start
+}
+
+// New creates a Console connecting to the server as configured, rate limited by limiter.
+func New(state *types.ServerState, limiter *utils.RateLimiter, config Config) *Console {
+	return &Console{
+		state:   state,
+		limiter: limiter,
+		config:  config,
+		waiting: make(chan struct{}, queueSize),
+		turn:    make(chan struct{}, 1),
+	}
+}
+
+// Attach directs the commands to a newly started process. The connection to the previous
+// process, if any, is replaced on the next command.
+func (c *Console) Attach() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.running = true
+	c.run++
+}
+
+// Detach stops sending commands once the process exited.
+func (c *Console) Detach() {
+	c.mu.Lock()
+	c.running = false
+	c.mu.Unlock()
+
+	// The connection is closed now unless a command uses it, which then closes it
+	select {
+	case c.turn <- struct{}{}:
+		c.disconnect()
+		<-c.turn
+	default:
+	}
+}
+
+// Kick disconnects a player, identified by name or Steam ID.
+func (c *Console) Kick(ctx context.Context, player, reason string) error {
+	return c.Send(ctx, commandKick, player, reason)
+}
+
+// Ban bans a player, identified by name or Steam ID.
+func (c *Console) Ban(ctx context.Context, player, reason string) error {
+	return c.Send(ctx, commandBan, player, reason)
+}
+
+// Broadcast sends a chat message to every player.
+func (c *Console) Broadcast(ctx context.Context, message string) error {
+	return c.Send(ctx, commandSay, message)
+}
+
+// NextSession ends the current session and moves to the next one.
+func (c *Console) NextSession(ctx context.Context) error {
+	return c.Send(ctx, commandNextSession)
+}
+
+// RestartSession restarts the current session.
+func (c *Console) RestartSession(ctx context.Context) error {
+	return c.Send(ctx, commandRestartSession)
+}
+
+// Lock closes the server to new players for the lifetime of the process by setting a random
+// server password. Connected players stay connected.
+func (c *Console) Lock(ctx context.Context) error {
+	password := make([]byte, 16)
+	if _, err := rand.Read(password); err != nil {
+		return err
+	}
+	return c.Send(ctx, commandSet, passwordProperty, hex.EncodeToString(password))
+}
+
+// Send sends a command with its arguments to the server, waiting for the rate limit and for
+// the reply. Empty arguments are omitted. A command whose reply outlasts ctx may still be
+// executed.
+func (c *Console) Send(ctx context.Context, command string, args ...string) error {
+	start := time.Now()
+	err := c.send(ctx, command, args)
+
+	labels := c.labels()
+	labels["command_type"] = command
+	metrics.CommandProcessingTimeHistogram.With(labels).Observe(time.Since(start).Seconds())
+	labels["outcome"] = "success"
+	if err != nil {
+		labels["outcome"] = "failure"
+	}
+	metrics.CommandsCounter.With(labels).Inc()
+	return err
+}
+
+// send formats the command line and executes it on the server.
+func (c *Console) send(ctx context.Context, command string, args []string) error {
+	line, logged := command, command
+	for i, arg := range args {
+		// A line break would let an argument inject a second command
+		if strings.ContainsAny(arg, "\r\n\x00") {
+			return fmt.Errorf("invalid argument %q: line breaks and null bytes are not allowed", arg)
+		}
+		if arg = strings.TrimSpace(arg); arg != "" {
+			line += " " + arg
+			// The password value stays out of the logs
+			if command == commandSet && i == 1 && args[0] == passwordProperty {
+				arg = "***"
+			}
+			logged += " " + arg
+		}
+	}
+
+	if err := c.limiter.WaitCommand(ctx); err != nil {
+		return err
+	}
+
+	reply, err := c.exec(ctx, line)
+	if err != nil {
+		return fmt.Errorf("failed to send %s: %w", command, err)
+	}
+	reply = strings.TrimSpace(reply)
+	for _, rejection := range rejections {
+		if strings.HasPrefix(reply, rejection) {
+			return fmt.Errorf("%w: %s", ErrRejected, reply)
+		}
+	}
+	utils.LogEvent("COMMAND", "Sent %s", logged)
+	return nil
+}
+
+// exec executes a command line on the running process, connecting to it if needed, and
+// returns its reply.
+func (c *Console) exec(ctx context.Context, line string) (string, error) {
+	c.mu.Lock()
+	running, run := c.running, c.run
+	c.mu.Unlock()
+	if !running {
+		return "", ErrNotRunning
+	}
+
+	select {
+	case c.waiting <- struct{}{}:
+		defer func() { <-c.waiting }()
+	default:
+		return "", ErrBusy
+	}
+	select {
+	case c.turn <- struct{}{}:
+		defer func() { <-c.turn }()
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+
+	if c.conn != nil && c.connRun != run {
+		c.disconnect()
+	}
+	if c.conn == nil {
+		conn, err := dialRcon(ctx, c.config.Address, c.config.Password)
+		if err != nil {
+			return "", err
+		}
+		c.conn, c.connRun = conn, run
+	}
+	reply, err := c.conn.exec(ctx, line)
+	if err != nil {
+		// The connection may be out of step with the server
+		c.disconnect()
+	}
+	return reply, err
+}
+
+// disconnect closes the connection to the server. The caller holds the turn.
+func (c *Console) disconnect() {
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+}
+
+// labels returns the server labels of the command metrics.
+func (c *Console) labels() prometheus.Labels {
+	c.state.RLock()
+	defer c.state.RUnlock()
+	return prometheus.Labels{
+		"server_id":   c.state.ServerID,
+		"server_name": c.state.ServerName,
+		"server_type": c.state.ServerType,
+	}
+}
@@ -0,0 +1,240 @@
+package console
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"agones/types"
+	"agones/utils"
+)
+
+// rconServer is a fake RCON listener of AssettoServer recording the commands it executes.
+type rconServer struct {
+	net.Listener
+	password string
+	reply    func(command string) string // Reply to a command, nil never to answer
+
+	mu       sync.Mutex
+	commands []string
+	auths    int
+}
+
+func newRconServer(t *testing.T, password string, reply func(string) string) *rconServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &rconServer{Listener: listener, password: password, reply: reply}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+// serve handles a connection as RconClient does: an authentication, then commands.
+func (s *rconServer) serve(conn net.Conn) {
+	defer conn.Close()
+	authenticated := false
+	for {
+		var header [4]byte
+		if _, err := io.ReadFull(conn, header[:]); err != nil {
+			return
+		}
+		packet := make([]byte, binary.LittleEndian.Uint32(header[:]))
+		if _, err := io.ReadFull(conn, packet); err != nil {
+			return
+		}
+		id := int32(binary.LittleEndian.Uint32(packet))
+		typ := int32(binary.LittleEndian.Uint32(packet[4:]))
+		body := string(packet[8 : len(packet)-2])
+
+		switch {
+		case !authenticated && typ == rconAuth:
+			s.mu.Lock()
+			s.auths++
+			s.mu.Unlock()
+			if body != s.password {
+				writePacket(conn, -1, rconAuthResponse, "")
+				return
+			}
+			authenticated = true
+			writePacket(conn, id, rconAuthResponse, "")
+		case authenticated && typ == rconExecCommand:
+			s.mu.Lock()
+			s.commands = append(s.commands, body)
+			s.mu.Unlock()
+			if s.reply != nil {
+				writePacket(conn, id, rconResponseValue, s.reply(body))
+			}
+		default:
+			return
+		}
+	}
+}
+
+func writePacket(w io.Writer, id, typ int32, body string) {
+	packet := binary.LittleEndian.AppendUint32(nil, uint32(rconHeaderSize+len(body)))
+	packet = binary.LittleEndian.AppendUint32(packet, uint32(id))
+	packet = binary.LittleEndian.AppendUint32(packet, uint32(typ))
+	packet = append(append(packet, body...), 0, 0)
+	w.Write(packet)
+}
+
+func (s *rconServer) executed() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+func (s *rconServer) authentications() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.auths
+}
+
+// replyOK answers every command as the admin commands of AssettoServer do on success.
+func replyOK(command string) string {
+	if strings.HasPrefix(command, "set ") {
+		fields := strings.Fields(command)
+		return "Property " + fields[1] + " set to " + fields[2] + "\n"
+	}
+	return "OK\n"
+}
+
+func newTestConsole(s *rconServer, password string) *Console {
+	return New(&types.ServerState{}, utils.NewRateLimiter(), Config{Address: s.Addr().String(), Password: password})
+}
+
+func TestSend(t *testing.T) {
+	server := newRconServer(t, "admin", replyOK)
+	c := newTestConsole(server, "admin")
+	ctx := context.Background()
+	if err := c.Broadcast(ctx, "hello"); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("Broadcast without a process = %v, want ErrNotRunning", err)
+	}
+
+	c.Attach()
+	defer c.Detach()
+	if err := c.Kick(ctx, "76561198000000001", " "); err != nil {
+		t.Fatalf("Kick: %v", err)
+	}
+	if err := c.Broadcast(ctx, "hello\nban Bob"); err == nil {
+		t.Error("Broadcast of a line break succeeded")
+	}
+	if err := c.Broadcast(ctx, "race starts in 5 minutes"); err != nil {
+		t.Fatalf("Broadcast: %v", err)
+	}
+
+	want := []string{"kick 76561198000000001", "say race starts in 5 minutes"}
+	if got := server.executed(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("executed %q, want %q", got, want)
+	}
+	if n := server.authentications(); n != 1 {
+		t.Errorf("%d authentications, want a single connection", n)
+	}
+}
+
+func TestSendReconnects(t *testing.T) {
+	server := newRconServer(t, "admin", replyOK)
+	c := newTestConsole(server, "admin")
+	ctx := context.Background()
+
+	// Every process start gets a new connection
+	for i := 0; i < 2; i++ {
+		c.Attach()
+		if err := c.NextSession(ctx); err != nil {
+			t.Fatalf("NextSession #%d: %v", i+1, err)
+		}
+		c.Detach()
+	}
+	if n := server.authentications(); n != 2 {
+		t.Errorf("%d authentications, want 2", n)
+	}
+}
+
+func TestSendRejected(t *testing.T) {
+	server := newRconServer(t, "admin", func(string) string { return "This player is not connected.\n" })
+	c := newTestConsole(server, "admin")
+	c.Attach()
+	defer c.Detach()
+
+	if err := c.Ban(context.Background(), "Nobody", "cheating"); !errors.Is(err, ErrRejected) {
+		t.Errorf("Ban of an unknown player = %v, want ErrRejected", err)
+	}
+}
+
+func TestSendWrongPassword(t *testing.T) {
+	server := newRconServer(t, "admin", replyOK)
+	c := newTestConsole(server, "wrong")
+	c.Attach()
+	defer c.Detach()
+
+	if err := c.RestartSession(context.Background()); !errors.Is(err, ErrAuth) {
+		t.Errorf("RestartSession with a wrong password = %v, want ErrAuth", err)
+	}
+	if got := server.executed(); len(got) != 0 {
+		t.Errorf("executed %q without authentication", got)
+	}
+}
+
+func TestLock(t *testing.T) {
+	server := newRconServer(t, "admin", replyOK)
+	c := newTestConsole(server, "admin")
+	c.Attach()
+	defer c.Detach()
+
+	if err := c.Lock(context.Background()); err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+	got := server.executed()
+	if len(got) != 1 || !regexp.MustCompile(`^set Server\.Password [0-9a-f]{32}$`).MatchString(got[0]) {
+		t.Errorf("executed %q, want a random server password", got)
+	}
+}
+
+func TestSendToStuckServer(t *testing.T) {
+	// A server that never answers its commands
+	server := newRconServer(t, "admin", nil)
+	c := newTestConsole(server, "admin")
+	c.Attach()
+
+	send := func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		return c.Broadcast(ctx, "hello")
+	}
+	if err := send(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Broadcast = %v, want the context deadline", err)
+	}
+
+	// Commands waiting for the server fill up the queue, without blocking the callers
+	for i := 0; i < queueSize; i++ {
+		c.waiting <- struct{}{}
+	}
+	if err := c.Broadcast(context.Background(), "hello"); !errors.Is(err, ErrBusy) {
+		t.Errorf("Broadcast with a full queue = %v, want ErrBusy", err)
+	}
+	for i := 0; i < queueSize; i++ {
+		<-c.waiting
+	}
+
+	c.Detach()
+	if err := c.Broadcast(context.Background(), "hello"); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("Broadcast after Detach = %v, want ErrNotRunning", err)
+	}
+}
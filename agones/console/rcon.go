@@ -0,0 +1,164 @@
+package console
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// Packet types of the Source RCON protocol, as implemented by AssettoServer.
+const (
+	rconResponseValue = 0 // Reply to a command
+	rconAuthResponse  = 2 // Reply to an authentication, with the request ID -1 on failure
+	rconExecCommand   = 2 // Command to execute
+	rconAuth          = 3 // Authentication with the admin password
+)
+
+// Packet size limits. AssettoServer reads the packets into a 2046-byte buffer, size included,
+// and writes them from a 4096-byte one.
+const (
+	rconMaxRequest  = 2046 - 4
+	rconMaxResponse = 4096 - 4
+	rconHeaderSize  = 4 + 4 + 2 // Request ID, type and the two terminating null bytes
+)
+
+// ErrAuth is returned when the server refuses the RCON password.
+var ErrAuth = errors.New("RCON authentication failed, check that the RCON password is the server admin password")
+
+// dialTimeout bounds the time spent connecting to the RCON listener.
+const dialTimeout = 5 * time.Second
+
+// rconConn is an authenticated connection to the RCON listener of the server.
+type rconConn struct {
+	conn   net.Conn
+	nextID int32
+}
+
+// dialRcon connects to the RCON listener at address and authenticates with password.
+func dialRcon(ctx context.Context, address, password string) (*rconConn, error) {
+	dialer := net.Dialer{Timeout: dialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to RCON: %w", err)
+	}
+
+	c := &rconConn{conn: conn}
+	err = c.withContext(ctx, func() error {
+		id := c.id()
+		if err := c.write(id, rconAuth, password); err != nil {
+			return err
+		}
+		// The server only answers an authentication with an authentication response
+		respID, typ, _, err := c.read()
+		switch {
+		case err != nil:
+			return err
+		case typ != rconAuthResponse:
+			return fmt.Errorf("unexpected RCON packet type %d", typ)
+		case respID == -1:
+			return ErrAuth
+		case respID != id:
+			return fmt.Errorf("unexpected RCON request ID %d", respID)
+		}
+		return nil
+	})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// exec executes a command and returns the reply of the server.
+func (c *rconConn) exec(ctx context.Context, command string) (string, error) {
+	var reply string
+	err := c.withContext(ctx, func() error {
+		id := c.id()
+		if err := c.write(id, rconExecCommand, command); err != nil {
+			return err
+		}
+		for {
+			respID, typ, body, err := c.read()
+			if err != nil {
+				return err
+			}
+			// Replies to earlier commands abandoned by their caller are skipped
+			if typ == rconResponseValue && respID == id {
+				reply = body
+				return nil
+			}
+		}
+	})
+	return reply, err
+}
+
+// withContext runs an exchange on the connection, aborting it when ctx is done.
+func (c *rconConn) withContext(ctx context.Context, exchange func() error) error {
+	stop := context.AfterFunc(ctx, func() {
+		c.conn.SetDeadline(time.Now())
+	})
+	err := exchange()
+	if !stop() || ctx.Err() != nil {
+		// The deadline set by AfterFunc may have interrupted the exchange
+		return ctx.Err()
+	}
+	return err
+}
+
+// id returns a new request ID, never -1 which denotes a failed authentication.
+func (c *rconConn) id() int32 {
+	c.nextID++
+	if c.nextID < 0 {
+		c.nextID = 1
+	}
+	return c.nextID
+}
+
+// write writes a packet: its size, request ID, type and body, followed by two null bytes.
+func (c *rconConn) write(id, typ int32, body string) error {
+	size := rconHeaderSize + len(body)
+	if size > rconMaxRequest {
+		return fmt.Errorf("RCON command too long (%d bytes)", len(body))
+	}
+	packet := make([]byte, 0, 4+size)
+	packet = binary.LittleEndian.AppendUint32(packet, uint32(size))
+	packet = binary.LittleEndian.AppendUint32(packet, uint32(id))
+	packet = binary.LittleEndian.AppendUint32(packet, uint32(typ))
+	packet = append(packet, body...)
+	packet = append(packet, 0, 0)
+	_, err := c.conn.Write(packet)
+	return err
+}
+
+// read reads a packet and returns its request ID, type and body.
+func (c *rconConn) read() (int32, int32, string, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(c.conn, header[:]); err != nil {
+		return 0, 0, "", err
+	}
+	size := int(binary.LittleEndian.Uint32(header[:]))
+	if size < 4+4 || size > rconMaxResponse {
+		return 0, 0, "", fmt.Errorf("invalid RCON packet size %d", size)
+	}
+	packet := make([]byte, size)
+	if _, err := io.ReadFull(c.conn, packet); err != nil {
+		return 0, 0, "", err
+	}
+	id := int32(binary.LittleEndian.Uint32(packet))
+	typ := int32(binary.LittleEndian.Uint32(packet[4:]))
+	body := packet[8:]
+	if i := bytes.IndexByte(body, 0); i != -1 {
+		body = body[:i]
+	}
+	return id, typ, string(body), nil
+}
+
+// Close closes the connection.
+func (c *rconConn) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,190 @@
+// Package drain implements the drain phase preceding a shutdown: players are warned and
+// given time to finish their race before the server goes away.
+package drain
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"agones/metrics"
+	"agones/session"
+	"agones/types"
+	"agones/utils"
+)
+
+// ErrDraining is returned when a drain is requested while one is in progress.
+var ErrDraining = errors.New("server is already draining")
+
+// Drain outcomes.
+const (
+	OutcomeEmpty        = "empty"         // No players left
+	OutcomeRaceFinished = "race_finished" // The race session ended
+	OutcomeTimeout      = "timeout"       // The drain deadline elapsed
+	OutcomeSkipped      = "skipped"       // The drain was ended early
+)
+
+// Config configures the drain phase.
+type Config struct {
+	Lock      func() error               // Stops the server from accepting new players, may be nil
+	Broadcast func(message string) error // Sends a chat message to every player, may be nil
+}
+
+// Drainer drains the server before a shutdown.
+type Drainer struct {
+	state    *types.ServerState
+	sessions *session.SessionManager
+	config   Config
+
+	mu   sync.Mutex
+	skip chan struct{} // Closed to end the drain in progress, nil when not draining
+}
+
+// NewDrainer creates a Drainer for the server.
+func NewDrainer(state *types.ServerState, sessions *session.SessionManager, config Config) *Drainer {
+	return &Drainer{
+		state:    state,
+		sessions: sessions,
+		config:   config,
+	}
+}
+
+// Start starts draining the server in the background. The drain ends once no players are
+// left, the race session finished or timeout elapsed, and done is then called with the outcome.
+func (d *Drainer) Start(reason string, timeout time.Duration, done func(outcome string)) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.skip != nil {
+		return ErrDraining
+	}
+	d.skip = make(chan struct{})
+
+	d.state.Lock()
+	d.state.Draining = true
+	d.state.Unlock()
+
+	utils.LogEvent("DRAIN", "Draining the server for up to %v (%s)", timeout, reason)
+	if d.config.Lock != nil {
+		if err := d.config.Lock(); err != nil {
+			utils.LogWarning("Failed to stop accepting new players: %v", err)
+		}
+	}
+
+	go func() {
+		outcome := d.run(timeout, d.skip)
+		utils.LogEvent("DRAIN", "Drain ended: %s", outcome)
+		d.record(outcome)
+		done(outcome)
+	}()
+	return nil
+}
+
+// Skip ends the drain in progress, if any, immediately.
+func (d *Drainer) Skip() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.skip != nil {
+		select {
+		case <-d.skip:
+		default:
+			close(d.skip)
+		}
+	}
+}
+
+// Draining returns whether a drain was started.
+func (d *Drainer) Draining() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.skip != nil
+}
+
+// run waits for the end of the drain, counting down to the deadline.
+func (d *Drainer) run(timeout time.Duration, skip <-chan struct{}) string {
+	transitions, unsubscribe := d.sessions.Subscribe(4)
+	defer unsubscribe()
+
+	deadline := time.Now().Add(timeout)
+	announcements := countdown(timeout)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		remaining := time.Until(deadline)
+		if len(announcements) > 0 && remaining <= announcements[0] {
+			d.broadcast(fmt.Sprintf("The server will shut down in %v, please finish your race", remaining.Round(time.Second)))
+			for len(announcements) > 0 && remaining <= announcements[0] {
+				announcements = announcements[1:]
+			}
+		}
+
+		d.state.RLock()
+		players := d.state.Players
+		d.state.RUnlock()
+		if players == 0 {
+			return OutcomeEmpty
+		}
+		if remaining <= 0 {
+			return OutcomeTimeout
+		}
+
+		select {
+		case <-skip:
+			return OutcomeSkipped
+		case transition, ok := <-transitions:
+			if !ok {
+				transitions = nil
+				continue
+			}
+			if transition.Ended != nil && transition.Ended.Type == types.SessionTypeRace {
+				d.broadcast("The race is over, the server is shutting down")
+				return OutcomeRaceFinished
+			}
+		case <-ticker.C:
+		}
+	}
+}
+
+// countdown returns the remaining times at which the shutdown is announced: at the start,
+// every minute, then 30 and 10 seconds before the deadline.
+func countdown(timeout time.Duration) []time.Duration {
+	announcements := []time.Duration{timeout}
+	for remaining := timeout.Truncate(time.Minute); remaining >= time.Minute; remaining -= time.Minute {
+		if remaining < timeout {
+			announcements = append(announcements, remaining)
+		}
+	}
+	for _, remaining := range []time.Duration{30 * time.Second, 10 * time.Second} {
+		if remaining < timeout {
+			announcements = append(announcements, remaining)
+		}
+	}
+	return announcements
+}
+
+// broadcast sends a chat message to the players, if broadcasting is available.
+func (d *Drainer) broadcast(message string) {
+	utils.LogSDK("Drain announcement: %s", message)
+	if d.config.Broadcast == nil {
+		return
+	}
+	if err := d.config.Broadcast(message); err != nil {
+		utils.LogWarning("Failed to broadcast drain announcement: %v", err)
+	}
+}
+
+// record counts a drain with its outcome.
+func (d *Drainer) record(outcome string) {
+	d.state.RLock()
+	labels := prometheus.Labels{
+		"server_id":   d.state.ServerID,
+		"server_name": d.state.ServerName,
+		"server_type": d.state.ServerType,
+		"outcome":     outcome,
+	}
+	d.state.RUnlock()
+	metrics.DrainsCounter.With(labels).Inc()
+}
@@ -0,0 +1,133 @@
+package drain
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"agones/session"
+	"agones/types"
+)
+
+func TestCountdown(t *testing.T) {
+	tests := []struct {
+		timeout time.Duration
+		want    []time.Duration
+	}{
+		{5 * time.Second, []time.Duration{5 * time.Second}},
+		{45 * time.Second, []time.Duration{45 * time.Second, 30 * time.Second, 10 * time.Second}},
+		{time.Minute, []time.Duration{time.Minute, 30 * time.Second, 10 * time.Second}},
+		{2 * time.Minute, []time.Duration{2 * time.Minute, time.Minute, 30 * time.Second, 10 * time.Second}},
+		{150 * time.Second, []time.Duration{150 * time.Second, 2 * time.Minute, time.Minute, 30 * time.Second, 10 * time.Second}},
+	}
+	for _, tt := range tests {
+		if got := countdown(tt.timeout); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("countdown(%v) = %v, want %v", tt.timeout, got, tt.want)
+		}
+	}
+}
+
+// announcements records the broadcast drain announcements.
+type announcements struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (a *announcements) broadcast(message string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.messages = append(a.messages, message)
+	return nil
+}
+
+func (a *announcements) get() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]string(nil), a.messages...)
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		players int
+		timeout time.Duration
+		end     func(sessions *session.SessionManager, skip chan struct{})
+		want    string
+	}{
+		{"empty", 0, time.Minute, nil, OutcomeEmpty},
+		{"timeout", 2, 10 * time.Millisecond, nil, OutcomeTimeout},
+		{"skip", 2, time.Minute, func(_ *session.SessionManager, skip chan struct{}) { close(skip) }, OutcomeSkipped},
+		{"race finished", 2, time.Minute, func(sessions *session.SessionManager, _ chan struct{}) {
+			// The transitions published before the drain subscribes are missed
+			sessions.StartNewSession(types.SessionTypeRace, "monza")
+			sessions.EndCurrentSession()
+		}, OutcomeRaceFinished},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := &types.ServerState{Players: tt.players}
+			sessions := session.NewSessionManager(10)
+			var messages announcements
+			d := NewDrainer(state, sessions, Config{Broadcast: messages.broadcast})
+
+			skip := make(chan struct{})
+			outcome := make(chan string, 1)
+			go func() { outcome <- d.run(tt.timeout, skip) }()
+
+			var got string
+			for deadline := time.After(5 * time.Second); got == ""; {
+				select {
+				case got = <-outcome:
+				case <-deadline:
+					t.Fatal("drain never ended")
+				case <-time.After(20 * time.Millisecond):
+					if tt.end != nil {
+						tt.end(sessions, skip)
+						if tt.want == OutcomeSkipped {
+							tt.end = nil
+						}
+					}
+				}
+			}
+			if got != tt.want {
+				t.Errorf("outcome = %s, want %s", got, tt.want)
+			}
+			if tt.players > 0 && len(messages.get()) == 0 {
+				t.Error("no drain announcement")
+			}
+		})
+	}
+}
+
+func TestStart(t *testing.T) {
+	state := &types.ServerState{Players: 1}
+	locks := 0
+	d := NewDrainer(state, session.NewSessionManager(10), Config{
+		Lock: func() error {
+			locks++
+			return nil
+		},
+	})
+
+	done := make(chan string, 1)
+	if err := d.Start("test", time.Minute, func(outcome string) { done <- outcome }); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if err := d.Start("test", time.Minute, func(string) {}); err != ErrDraining {
+		t.Errorf("second Start = %v, want ErrDraining", err)
+	}
+	if locks != 1 || !state.Draining || !d.Draining() {
+		t.Errorf("locks = %d, state.Draining = %v, Draining() = %v, want 1, true, true", locks, state.Draining, d.Draining())
+	}
+
+	d.Skip()
+	select {
+	case outcome := <-done:
+		if outcome != OutcomeSkipped {
+			t.Errorf("outcome = %s, want %s", outcome, OutcomeSkipped)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("drain never ended")
+	}
+}
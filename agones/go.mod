@@ -6,6 +6,7 @@ require (
 	agones.dev/agones v1.35.0
 	github.com/prometheus/client_golang v1.17.0
 	golang.org/x/time v0.5.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+
+	"agones/parser"
+	"agones/types"
+	"agones/utils"
+)
+
+// The helpers below read event data from the structured properties of CLEF entries,
+// falling back to parsing the text line when the entry has no properties.
+
+// playerFromEntry extracts player information from an entry.
+func playerFromEntry(entry parser.Entry) types.Player {
+	if entry.Properties == nil {
+		return utils.ExtractPlayerInfo(entry.Raw)
+	}
+
+	return types.Player{
+		Name:     entry.Property("ClientName"),
+		SteamID:  entry.Property("ClientSteamId", "SteamId"),
+		CarModel: entry.Property("CarModel"),
+	}
+}
+
+// steamIDFromEntry extracts the Steam ID of the player an entry refers to.
+func steamIDFromEntry(entry parser.Entry) string {
+	if entry.Properties == nil {
+		return utils.ExtractSteamID(entry.Raw)
+	}
+	return entry.Property("ClientSteamId", "SteamId")
+}
+
+// disconnectedPlayerFromEntry extracts the Steam ID and name of a disconnecting player.
+// The text format of the disconnect line only carries the name.
+func disconnectedPlayerFromEntry(entry parser.Entry) (string, string) {
+	if entry.Properties == nil {
+		name := strings.TrimSpace(strings.Split(entry.Message, "has disconnected")[0])
+		return utils.ExtractSteamID(entry.Raw), name
+	}
+	return entry.Property("ClientSteamId", "SteamId"), entry.Property("ClientName")
+}
+
+// lapFromEntry extracts the driver name, number of cuts and lap time (ms) from a lap completion entry.
+func lapFromEntry(entry parser.Entry) (string, int, int64) {
+	if entry.Properties == nil {
+		return utils.ExtractLap(entry.Raw)
+	}
+
+	cuts, _ := strconv.Atoi(entry.Property("NumCuts", "Cuts"))
+	lapTime, err := utils.ParseLapTime(entry.Property("LapTime"))
+	if err != nil {
+		return "", 0, 0
+	}
+	return entry.Property("ClientName"), cuts, lapTime
+}
+
+// moderationFromEntry extracts a moderation event of the given type from an entry.
+func moderationFromEntry(entry parser.Entry, eventType string) types.ModerationEvent {
+	event := types.ModerationEvent{Type: eventType, Timestamp: entry.Timestamp}
+	if entry.Properties == nil {
+		event.SteamID, event.Name, event.Reason, event.Actor = utils.ExtractModeration(entry.Raw)
+		return event
+	}
+
+	event.SteamID = entry.Property("ClientSteamId", "SteamId")
+	event.Name = entry.Property("ClientName")
+	event.Reason = entry.Property("Reason", "AuthResponseReason", "ErrorReason")
+	event.Actor = entry.Property("AdminName", "Admin")
+	return event
+}
+
+// sessionFromEntry extracts the session type and track from a session change entry.
+func sessionFromEntry(entry parser.Entry) (string, string) {
+	if entry.Properties == nil {
+		return utils.ExtractSessionType(entry.Raw), utils.ExtractTrackName(entry.Raw)
+	}
+
+	sessionType := utils.ExtractSessionType(entry.Property("SessionType", "SessionName"))
+	return sessionType, entry.Property("Track", "TrackName")
+}
+
+// sessionLengthFromEntry extracts the session length from a session change entry.
+func sessionLengthFromEntry(entry parser.Entry) string {
+	if entry.Properties == nil {
+		return utils.ExtractSessionLength(entry.Raw)
+	}
+	return entry.Property("Length")
+}
+
+// cspHandshakeFromEntry extracts the CSP version and player name from a CSP handshake entry.
+func cspHandshakeFromEntry(entry parser.Entry) (int, string) {
+	if entry.Properties == nil {
+		return utils.ExtractCSPVersion(entry.Raw), utils.ExtractCSPPlayerName(entry.Raw)
+	}
+
+	version, _ := strconv.Atoi(entry.Property("Version"))
+	name := entry.Property("ClientName")
+	if name == "" {
+		name = "unknown"
+	}
+	return version, name
+}
+
+// aiSlotsFromEntry extracts the AI slot information from an AI slot update entry.
+func aiSlotsFromEntry(entry parser.Entry) map[string]int {
+	if entry.Properties == nil {
+		return utils.ExtractAISlots(entry.Raw)
+	}
+
+	slots := make(map[string]int)
+	if num, err := strconv.Atoi(entry.Property("NumAiSlots")); err == nil {
+		slots["total"] = num
+	}
+	return slots
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"testing"
+
+	"agones/parser"
+	"agones/types"
+)
+
+func TestSessionFromEntry(t *testing.T) {
+	tests := []struct {
+		format parser.Format
+		line   string
+		typ    string
+		track  string
+	}{
+		{parser.FormatText, "[12:03:00 INF] Next session: Race - Length: 10 laps", types.SessionTypeRace, ""},
+		{parser.FormatText, "[12:03:00 INF] Next session: Qualify - Length: 15 minutes", types.SessionTypeQualifying, ""},
+		{parser.FormatText, "[12:03:00 INF] Next session: Practice - Length: 30 minutes", types.SessionTypePractice, ""},
+		{parser.FormatText, "[12:03:00 INF] Next session: RACE TRACK: monza", types.SessionTypeRace, "monza"},
+		{parser.FormatCLEF, `{"@t":"2024-05-10T12:03:00Z","@mt":"Next session: {SessionName} - Length: {Length}","SessionName":"Race","Length":"10 laps"}`, types.SessionTypeRace, ""},
+		{parser.FormatCLEF, `{"@t":"2024-05-10T12:03:00Z","@mt":"Next session: {SessionName} - Length: {Length}","SessionName":"Booking","Length":"10 minutes"}`, types.SessionTypeUnknown, ""},
+	}
+	for _, tt := range tests {
+		typ, track := sessionFromEntry(tt.format.Parse(tt.line))
+		if typ != tt.typ || track != tt.track {
+			t.Errorf("sessionFromEntry(%s) = %q, %q, want %q, %q", tt.line, typ, track, tt.typ, tt.track)
+		}
+	}
+}
+
+func TestPlayerFromEntry(t *testing.T) {
+	want := types.Player{Name: "Bob", SteamID: "76561198000000001", CarModel: "ks_mazda_miata"}
+	for _, line := range []string{
+		"[12:01:00 INF] Bob (76561198000000001, 0 (ks_mazda_miata-red)) has connected",
+		`{"@t":"2024-05-10T12:01:00Z","@mt":"{ClientName} ({ClientSteamId}, {SessionId} ({CarModel}-{CarSkin})) has connected","ClientName":"Bob","ClientSteamId":76561198000000001,"SessionId":0,"CarModel":"ks_mazda_miata","CarSkin":"red"}`,
+	} {
+		if got := playerFromEntry(parser.FormatCLEF.Parse(line)); got != want {
+			t.Errorf("playerFromEntry(%s) = %+v, want %+v", line, got, want)
+		}
+	}
+}
+
+func TestLapFromEntry(t *testing.T) {
+	for _, line := range []string{
+		"[12:02:00 INF] Lap completed by Bob, 2 cuts, laptime 01:32.4560",
+		`{"@t":"2024-05-10T12:02:00Z","@mt":"Lap completed by {ClientName}, {NumCuts} cuts, laptime {LapTime}","ClientName":"Bob","NumCuts":2,"LapTime":"01:32.4560"}`,
+	} {
+		name, cuts, lapTime := lapFromEntry(parser.FormatCLEF.Parse(line))
+		if name != "Bob" || cuts != 2 || lapTime != 92456 {
+			t.Errorf("lapFromEntry(%s) = %q, %d, %d, want Bob, 2, 92456", line, name, cuts, lapTime)
+		}
+	}
+}
+
+func TestSessionLengthFromEntry(t *testing.T) {
+	tests := []struct {
+		line   string
+		length string
+	}{
+		{"[12:03:00 INF] Next session: Race - Length: 10 laps", "10 laps"},
+		{"[12:03:00 INF] Next session: Practice - Length: 30 minutes", "30 minutes"},
+		{"[12:03:00 INF] Next session: Practice - Length: Infinite", "Infinite"},
+		{"[12:03:00 INF] Next session: RACE TRACK: monza", ""},
+		{`{"@t":"2024-05-10T12:03:00Z","@mt":"Next session: {SessionName} - Length: {Length}","SessionName":"Race","Length":"10 laps"}`, "10 laps"},
+	}
+	for _, tt := range tests {
+		if length := sessionLengthFromEntry(parser.FormatCLEF.Parse(tt.line)); length != tt.length {
+			t.Errorf("sessionLengthFromEntry(%s) = %q, want %q", tt.line, length, tt.length)
+		}
+	}
+}
+
+func TestModerationFromEntry(t *testing.T) {
+	tests := []struct {
+		line string
+		want types.ModerationEvent
+	}{
+		{"[12:07:00 INF] Bob was kicked. Reason: spamming", types.ModerationEvent{Name: "Bob", Reason: "spamming"}},
+		{"[12:07:00 INF] Bob Smith was banned after reloading blacklist", types.ModerationEvent{Name: "Bob Smith"}},
+		{"[12:07:00 INF] Bob (76561198000000001) is using Steam family sharing and game owner 76561198000000002 is blacklisted", types.ModerationEvent{SteamID: "76561198000000001", Name: "Bob"}},
+		{"[12:07:00 DBG] Sending AuthFailedResponse (You are not whitelisted on this server)", types.ModerationEvent{Reason: "You are not whitelisted on this server"}},
+		{"[12:07:00 WRN] Steam authentication failed for Bob (3): Invalid ticket", types.ModerationEvent{Name: "Bob", Reason: "Invalid ticket"}},
+		{`{"@t":"2024-05-10T12:07:00Z","@mt":"{ClientName} was kicked. Reason: {Reason}","ClientName":"Bob","Reason":"spamming","ClientSteamId":76561198000000001}`, types.ModerationEvent{SteamID: "76561198000000001", Name: "Bob", Reason: "spamming"}},
+		{`{"@t":"2024-05-10T12:07:00Z","@mt":"{ClientName} was banned. Reason: {Reason}","ClientName":"Bob","Reason":"No reason given.","ClientSteamId":76561198000000001}`, types.ModerationEvent{SteamID: "76561198000000001", Name: "Bob", Reason: "No reason given."}},
+		{`{"@t":"2024-05-10T12:07:00Z","@l":"Debug","@mt":"Sending {PacketName} ({AuthResponseReason})","PacketName":"AuthFailedResponse","AuthResponseReason":"You are not whitelisted on this server","ClientName":"Bob","ClientSteamId":76561198000000001}`, types.ModerationEvent{SteamID: "76561198000000001", Name: "Bob", Reason: "You are not whitelisted on this server"}},
+		{`{"@t":"2024-05-10T12:07:00Z","@l":"Warning","@mt":"Steam authentication failed for {ClientName} ({SessionId}): {ErrorReason}","ClientName":"Bob","SessionId":3,"ErrorReason":"Invalid ticket","ClientSteamId":76561198000000001}`, types.ModerationEvent{SteamID: "76561198000000001", Name: "Bob", Reason: "Invalid ticket"}},
+	}
+	for _, tt := range tests {
+		got := moderationFromEntry(parser.FormatCLEF.Parse(tt.line), types.ModerationKick)
+		got.Type, got.Timestamp = "", tt.want.Timestamp
+		if got != tt.want {
+			t.Errorf("moderationFromEntry(%s) = %+v, want %+v", tt.line, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	sdk "agones.dev/agones/sdks/go"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"agones/metrics"
+	"agones/parser"
+	"agones/rules"
+	"agones/types"
+	"agones/utils"
+)
+
+// outputContext carries everything a rule action needs to act on a line of output.
+type outputContext struct {
+	entry       parser.Entry
+	sdk         *sdk.SDK
+	state       *types.ServerState
+	serverReady chan struct{}
+	cancel      context.CancelFunc
+	labels      prometheus.Labels
+}
+
+// outputHandler is a built-in handler that can be referenced by rules with the handler action.
+type outputHandler func(c *outputContext, output string)
+
+// builtinHandlers maps handler names to the functions implementing them.
+var builtinHandlers = map[string]outputHandler{
+	"attempting_to_connect": func(c *outputContext, o string) { handleAttemptingToConnect(o, c.state, c.labels) },
+	"extra_csp_features":    func(c *outputContext, o string) { handleExtraCSPFeatures(o, c.state, c.labels) },
+	"server_starting":       func(c *outputContext, _ string) { handleServerStarting(c.state, c.labels) },
+	"server_ready":          func(c *outputContext, _ string) { handleServerReady(c.state, c.labels, c.serverReady) },
+	"session_end":           func(c *outputContext, _ string) { handleSessionEnd(c.sdk, c.state, c.labels, c.cancel) },
+	"player_connect":        func(c *outputContext, o string) { handlePlayerConnect(c.sdk, c.state, c.entry, c.labels) },
+	"player_disconnect":     func(c *outputContext, o string) { handlePlayerDisconnect(c.sdk, c.state, c.entry, c.labels) },
+	"session_change":        func(c *outputContext, o string) { handleSessionChange(c.sdk, c.state, c.entry, c.labels, c.cancel) },
+	"lap_completed":         func(c *outputContext, _ string) { handleLapCompleted(c.state, c.entry, c.labels) },
+	"kick":                  func(c *outputContext, _ string) { handleModeration(c.entry, types.ModerationKick) },
+	"ban":                   func(c *outputContext, _ string) { handleModeration(c.entry, types.ModerationBan) },
+	"blacklist_reject":      func(c *outputContext, _ string) { handleModeration(c.entry, types.ModerationBlacklistReject) },
+	"whitelist_reject":      func(c *outputContext, _ string) { handleModeration(c.entry, types.ModerationWhitelistReject) },
+	"auth_failure":          func(c *outputContext, _ string) { handleModeration(c.entry, types.ModerationAuthFailure) },
+	"server_error":          func(c *outputContext, o string) { handleError(fmt.Errorf("%s", o), "server_error", c.state, c.labels) },
+	"steam_auth":            func(c *outputContext, _ string) { handleSteamAuth(c.state, c.labels) },
+	"network_stats":         func(c *outputContext, o string) { handleNetworkStats(o, c.labels) },
+	"steam_error":           func(c *outputContext, o string) { handleSteamError(o, c.state, c.labels) },
+	"server_version":        func(c *outputContext, o string) { handleServerVersion(o, c.state, c.labels) },
+	"config_loading":        func(c *outputContext, o string) { handleConfigLoading(o, c.state, c.labels) },
+	"plugin_loading":        func(c *outputContext, o string) { handlePluginLoading(o, c.state, c.labels) },
+	"ai_slot":               func(c *outputContext, o string) { handleAISlotUpdate(c.entry, c.state, c.labels) },
+	"checksum":              func(c *outputContext, o string) { handleChecksumUpdate(o, c.state, c.labels) },
+	"server_invite":         func(c *outputContext, o string) { handleServerInvite(o, c.state, c.labels) },
+	"session_switch":        func(c *outputContext, o string) { handleSessionSwitch(o, c.state, c.labels) },
+	"tcp_server":            func(c *outputContext, o string) { handleTCPServer(o, c.state, c.labels) },
+	"udp_server":            func(c *outputContext, o string) { handleUDPServer(o, c.state, c.labels) },
+	"session_time":          func(c *outputContext, o string) { handleSessionTime(o, c.state, c.labels) },
+	"lobby_registration":    func(c *outputContext, o string) { handleLobbyRegistration(o, c.state, c.labels) },
+	"update_loop":           func(c *outputContext, o string) { handleUpdateLoop(o, c.state, c.labels) },
+	"lobby_success":         func(c *outputContext, o string) { handleLobbySuccess(o, c.state, c.labels) },
+	"csp_version":           func(c *outputContext, o string) { handleCSPVersion(o, c.state, c.labels) },
+	"ai_spline":             func(c *outputContext, o string) { handleAISpline(o, c.state, c.labels) },
+	"ai_lane_detection":     func(c *outputContext, o string) { handleAILaneDetection(o, c.state, c.labels) },
+	"ai_spline_cache":       func(c *outputContext, o string) { handleAISplineCache(o, c.state, c.labels) },
+	"ai_spline_mapping":     func(c *outputContext, o string) { handleAISplineMapping(o, c.state, c.labels) },
+	"keys_storage":          func(c *outputContext, o string) { handleKeysStorage(o, c.state, c.labels) },
+	"xml_encryption":        func(c *outputContext, o string) { handleXMLEncryption(o, c.state, c.labels) },
+	"blacklist_loading":     func(c *outputContext, o string) { handleBlacklistLoading(o, c.state, c.labels) },
+	"whitelist_loading":     func(c *outputContext, o string) { handleWhitelistLoading(o, c.state, c.labels) },
+	"admins_loading":        func(c *outputContext, o string) { handleAdminsLoading(o, c.state, c.labels) },
+	"steam_connection":      func(c *outputContext, o string) { handleSteamConnection(o, c.state, c.labels) },
+	"csp_handshake":         func(c *outputContext, o string) { handleCSPHandshake(c.entry, c.state, c.labels) },
+	"chat_message":          func(c *outputContext, o string) { handleChatMessage(o, c.state, c.labels) },
+	"clean_exit":            func(c *outputContext, o string) { handleCleanExit(c.entry, c.state, c.labels) },
+}
+
+// stateSetters maps state field names to setters usable with the set_state action.
+var stateSetters = map[string]func(state *types.ServerState, value string) error{
+	"session_type": func(state *types.ServerState, value string) error {
+		state.SessionType = value
+		return nil
+	},
+	"current_track": func(state *types.ServerState, value string) error {
+		state.CurrentTrack = value
+		return nil
+	},
+	"current_layout": func(state *types.ServerState, value string) error {
+		state.CurrentLayout = value
+		return nil
+	},
+	"track_temp":        floatSetter(func(state *types.ServerState, v float64) { state.TrackTemp = v }),
+	"air_temp":          floatSetter(func(state *types.ServerState, v float64) { state.AirTemp = v }),
+	"track_grip":        floatSetter(func(state *types.ServerState, v float64) { state.TrackGrip = v }),
+	"tick_rate":         floatSetter(func(state *types.ServerState, v float64) { state.TickRate = v }),
+	"session_time_left": intSetter(func(state *types.ServerState, v int) { state.SessionTimeLeft = v }),
+}
+
+// builtinRules returns the rules reproducing the historical output handling.
+// They are listed in evaluation order; priorities are derived from their position.
+func builtinRules() []rules.Rule {
+	defs := []struct {
+		name    string
+		pattern string
+		handler string // Defaults to the rule name
+		next    bool   // Keep evaluating after a match
+	}{
+		// Chat lines carry text typed by the players, which must not reach the other rules
+		{"chat_message", `^(?:\[[^\]]*\] )?CHAT: `, "", false},
+		{"attempting_to_connect", `is attempting to connect`, "", false},
+		{"extra_csp_features", `supports extra CSP features`, "", false},
+		{"server_starting", `Starting Assetto Corsa Server\.\.\.`, "", false},
+		// Lobby registration both marks the server ready and counts the registration
+		{"server_ready", `Lobby registration successful`, "", true},
+		{"session_end", `End of session`, "", false},
+		// Moderation lines are matched before the connection lines they may contain.
+		// Blacklisted players are rejected silently, except through the owner of a shared
+		// game, and whitelist rejects are only logged at the Debug level.
+		{"kick", `was kicked\. Reason:`, "", false},
+		{"ban", `was banned(?:\. Reason:| after reloading blacklist)`, "", false},
+		{"blacklist_reject", `is using Steam family sharing and game owner \d+ is blacklisted`, "", false},
+		{"whitelist_reject", `Sending AuthFailedResponse \(You are not whitelisted`, "", false},
+		{"auth_failure", `Steam authentication failed for`, "", false},
+		{"player_connect", `has connected`, "", false},
+		{"player_disconnect", `has disconnected`, "", false},
+		{"session_change", `Next session:`, "", false},
+		{"lap_completed", `Lap completed by`, "", false},
+		{"server_error", `\[(?:\d{2}:\d{2}:\d{2} )?ERR\]`, "", false},
+		{"steam_auth", `Steam authentication succeeded`, "", false},
+		{"network_stats", `Network stats`, "", false},
+		{"steam_error", `steamclient\.so|SteamAPI`, "", false},
+		{"server_version", `AssettoServer`, "", false},
+		{"config_loading", `Loading.*\.ini|\.ini.*Loading`, "", false},
+		{"plugin_loading", `Loaded plugin`, "", false},
+		{"ai_slot", `AI Slot`, "", false},
+		{"checksum", `Added checksum`, "", false},
+		{"server_invite", `Server invite link:`, "", false},
+		{"session_switch", `Switching session to id`, "", false},
+		{"tcp_server", `Starting TCP server`, "", false},
+		{"udp_server", `Starting UDP server`, "", false},
+		{"session_time", `Remaining time of session`, "", false},
+		{"lobby_registration", `Registering server to lobby`, "", false},
+		{"update_loop", `Starting update loop`, "", false},
+		{"lobby_success", `Lobby registration successful`, "", false},
+		{"extra_cfg_loading", `Loading extra_cfg\.yml`, "config_loading", false},
+		{"csp_version", `Using minimum required CSP Version`, "", false},
+		{"ai_spline", `Cached AI spline`, "", false},
+		{"ai_lane_detection", `Adjacent lane detection`, "", false},
+		{"ai_spline_cache", `Writing cached AI spline`, "", false},
+		{"ai_spline_mapping", `Mapping cached AI spline`, "", false},
+		{"keys_storage", `Storing keys in a directory`, "", false},
+		{"xml_encryption", `No XML encryptor configured`, "", false},
+		{"blacklist_loading", `Loaded blacklist\.txt`, "", false},
+		{"whitelist_loading", `Loaded whitelist\.txt`, "", false},
+		{"admins_loading", `Loaded admins\.txt`, "", false},
+		{"steam_connection", `Connected to Steam Servers`, "", false},
+		{"csp_handshake", `CSP handshake received`, "", false},
+		{"clean_exit", `Received clean exit`, "", false},
+	}
+
+	builtins := make([]rules.Rule, 0, len(defs))
+	for i, def := range defs {
+		target := def.handler
+		if target == "" {
+			target = def.name
+		}
+		builtins = append(builtins, rules.Rule{
+			Name:     def.name,
+			Pattern:  def.pattern,
+			Priority: 10 * (len(defs) - i),
+			Action:   rules.ActionHandler,
+			Target:   target,
+			Continue: def.next,
+		})
+	}
+	return builtins
+}
+
+// ruleEngine holds the active output rules, initialised with the built-in rules.
+var ruleEngine = func() *rules.Engine {
+	engine, err := rules.NewEngine(builtinRules())
+	if err != nil {
+		panic(fmt.Sprintf("invalid built-in rules: %v", err))
+	}
+	return engine
+}()
+
+// LoadRules overlays the rules defined in the file at path on top of the built-in rules.
+// Rules sharing the name of a built-in rule replace it.
+func LoadRules(path string) error {
+	custom, err := rules.LoadFile(path)
+	if err != nil {
+		return err
+	}
+
+	merged := rules.Merge(builtinRules(), custom)
+	for _, rule := range merged {
+		if err := checkRuleTarget(rule); err != nil {
+			return err
+		}
+	}
+
+	if err := ruleEngine.Load(merged); err != nil {
+		return err
+	}
+
+	utils.LogSDK("Loaded %d output rules from %s", len(custom), path)
+	return nil
+}
+
+// checkRuleTarget verifies that the target of a rule refers to something that exists.
+func checkRuleTarget(rule rules.Rule) error {
+	if rule.Disabled {
+		return nil
+	}
+
+	switch rule.Action {
+	case rules.ActionHandler:
+		if _, ok := builtinHandlers[rule.Target]; !ok {
+			return fmt.Errorf("rule %q: unknown handler %q", rule.Name, rule.Target)
+		}
+	case rules.ActionSetState:
+		if _, ok := stateSetters[rule.Target]; !ok {
+			return fmt.Errorf("rule %q: unknown state field %q", rule.Name, rule.Target)
+		}
+	case rules.ActionIncrementMetric:
+		if _, ok := metrics.LookupCounter(rule.Target); !ok {
+			return fmt.Errorf("rule %q: unknown metric %q", rule.Name, rule.Target)
+		}
+	}
+	return nil
+}
+
+// applyRule executes the action of a matched rule.
+func applyRule(c *outputContext, match *rules.Match) {
+	rule := match.Rule
+
+	ruleLabels := copyLabels(c.labels)
+	ruleLabels["rule"] = rule.Name
+	metrics.RuleMatchesCounter.With(ruleLabels).Inc()
+
+	switch rule.Action {
+	case rules.ActionHandler:
+		if handler, ok := builtinHandlers[rule.Target]; ok {
+			handler(c, match.Line)
+		}
+	case rules.ActionSetState:
+		setter, ok := stateSetters[rule.Target]
+		if !ok {
+			return
+		}
+		c.state.Lock()
+		err := setter(c.state, match.Expand(rule.Value))
+		c.state.Unlock()
+		if err != nil {
+			utils.LogWarning("Rule %s failed to set %s: %v", rule.Name, rule.Target, err)
+		}
+	case rules.ActionIncrementMetric:
+		if counter, ok := metrics.LookupCounter(rule.Target); ok {
+			counter.With(c.labels).Inc()
+		}
+	case rules.ActionSetAnnotation:
+		if err := c.sdk.SetAnnotation(rule.Target, match.Expand(rule.Value)); err != nil {
+			utils.LogWarning("Rule %s failed to set %s annotation: %v", rule.Name, rule.Target, err)
+		}
+	case rules.ActionEmitEvent:
+		message := match.Line
+		if rule.Value != "" {
+			message = match.Expand(rule.Value)
+		}
+		utils.LogEvent(rule.Target, "%s", message)
+	}
+}
+
+// floatSetter adapts a float field assignment to a state setter.
+func floatSetter(set func(state *types.ServerState, v float64)) func(*types.ServerState, string) error {
+	return func(state *types.ServerState, value string) error {
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		set(state, v)
+		return nil
+	}
+}
+
+// intSetter adapts an integer field assignment to a state setter.
+func intSetter(set func(state *types.ServerState, v int)) func(*types.ServerState, string) error {
+	return func(state *types.ServerState, value string) error {
+		v, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		set(state, v)
+		return nil
+	}
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"agones/moderation"
+	"agones/parser"
+	"agones/rules"
+	"agones/types"
+)
+
+func TestBuiltinRules(t *testing.T) {
+	// Lines as logged by AssettoServer
+	tests := []struct {
+		line string
+		want string // First matching rule, empty if none
+	}{
+		{"[12:00:00 INF] Starting Assetto Corsa Server...", "server_starting"},
+		{"[12:00:01 INF] Starting update loop with an update rate of 18hz", "update_loop"},
+		{"[12:00:02 INF] Lobby registration successful", "server_ready"},
+		{"[12:01:00 INF] Bob (76561198000000001, 0 (ks_mazda_miata-red)) has connected", "player_connect"},
+		{"[12:01:00 INF] Bob is attempting to connect (ks_mazda_miata-red)", "attempting_to_connect"},
+		{"[12:02:00 INF] Lap completed by Bob, 0 cuts, laptime 01:32.4560", "lap_completed"},
+		{"[12:03:00 INF] Next session: Race - Length: 10 laps", "session_change"},
+		{"[12:04:00 INF] Bob has disconnected", "player_disconnect"},
+		{"[12:05:00 INF] Steam authentication succeeded for Bob (76561198000000001)", "steam_auth"},
+		{"[12:05:00 INF] Connected to Steam Servers", "steam_connection"},
+		{"[12:05:00 INF] Loaded blacklist.txt with 3 entries", "blacklist_loading"},
+		{"[12:06:00 ERR] Unhandled exception", "server_error"},
+		{"[12:07:00 INF] Bob was kicked. Reason: No reason given.", "kick"},
+		{"[12:07:00 INF] Bob was banned. Reason: vote banned", "ban"},
+		{"[12:07:00 INF] Bob was banned after reloading blacklist", "ban"},
+		{"[12:07:00 INF] Bob (76561198000000001) is using Steam family sharing and game owner 76561198000000002 is blacklisted", "blacklist_reject"},
+		{"[12:07:00 INF] Bob (76561198000000001) is using Steam family sharing, owner 76561198000000002", ""},
+		{"[12:07:00 DBG] Sending AuthFailedResponse (You are not whitelisted on this server)", "whitelist_reject"},
+		{"[12:07:00 DBG] Sending AuthFailedResponse (Driver name cannot be empty.)", ""},
+		{"[12:07:00 WRN] Steam authentication failed for Bob (3): Invalid ticket", "auth_failure"},
+		{"[12:07:00 INF] CHAT: Server: Bob has been kicked.", "chat_message"},
+		{"[12:06:00 INF] Nothing interesting", ""},
+	}
+
+	engine, err := rules.NewEngine(builtinRules())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		got := ""
+		if matches := engine.Match(tt.line); len(matches) > 0 {
+			got = matches[0].Rule.Name
+		}
+		if got != tt.want {
+			t.Errorf("%q matched %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestChatLinesFireNoOtherRule(t *testing.T) {
+	engine, err := rules.NewEngine(builtinRules())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Chat lines as logged by AssettoServer: "CHAT: {ClientName} ({SessionId}): {ChatMessage}"
+	for _, message := range []string{
+		"Eve (76561198000000009, 3 (ks_mazda_miata-red)) has connected",
+		"End of session",
+		"Next session: Race - Length: 10 laps",
+		"Bob was kicked. Reason: cheating",
+		"Bob was banned. Reason: cheating",
+		"Lap completed by Eve, 0 cuts, laptime 00:01.0000",
+		"Lobby registration successful",
+		"Starting Assetto Corsa Server...",
+		"Received clean exit from Bob",
+		"[12:00:00 ERR] Unhandled exception",
+	} {
+		line := "[12:07:00 INF] CHAT: Eve (3): " + message
+		matches := engine.Match(line)
+		if len(matches) != 1 || matches[0].Rule.Name != "chat_message" {
+			var names []string
+			for _, match := range matches {
+				names = append(names, match.Rule.Name)
+			}
+			t.Errorf("%q matched %q, want only chat_message", line, names)
+		}
+	}
+}
+
+func TestChatLinesRecordNoModerationEvent(t *testing.T) {
+	var posted atomic.Int32
+	webhook := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		posted.Add(1)
+	}))
+	defer webhook.Close()
+
+	state := &types.ServerState{ServerID: "gs-1"}
+	feed := moderation.NewFeed(webhook.URL, state)
+	defer func(f *moderation.Feed) { moderationFeed = f }(moderationFeed)
+	SetModerationFeed(feed)
+
+	// Players typing the moderation lines of the server
+	for _, message := range []string{
+		"Bob was kicked. Reason: cheating",
+		"Bob was banned. Reason: cheating",
+		"Bob was banned after reloading blacklist",
+		"Bob (76561198000000001) is using Steam family sharing and game owner 76561198000000002 is blacklisted",
+		"Sending AuthFailedResponse (You are not whitelisted on this server)",
+		"Steam authentication failed for Bob (3): Invalid ticket",
+	} {
+		HandleServerOutput(parser.ParseText("[12:07:00 INF] CHAT: Eve (3): "+message), nil, state, nil, nil)
+	}
+	// The line of an actual kick is recorded
+	HandleServerOutput(parser.ParseText("[12:07:01 INF] Bob was kicked. Reason: No reason given."), nil, state, nil, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	feed.Run(ctx)
+	if n := posted.Load(); n != 1 {
+		t.Errorf("%d moderation events posted, want only the kick logged by the server", n)
+	}
+}
+
+func TestBuiltinRuleTargets(t *testing.T) {
+	for _, rule := range builtinRules() {
+		if err := checkRuleTarget(rule); err != nil {
+			t.Error(err)
+		}
+	}
+}
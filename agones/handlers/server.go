@@ -12,15 +12,21 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 
 	"agones/metrics"
+	"agones/moderation"
+	"agones/parser"
+	"agones/players"
+	"agones/session"
 	"agones/types"
 	"agones/utils"
+	"agones/webhook"
 )
 
-// HandleServerOutput processes server output and updates metrics.
-// It handles various server events based on the output string.
-func HandleServerOutput(output string, s *sdk.SDK, state *types.ServerState, serverReady chan struct{}, cancel context.CancelFunc) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// HandleServerOutput processes a parsed line of server output and updates metrics.
+// The raw line is evaluated against the output rules, whose actions update the
+// server state, metrics and annotations.
+func HandleServerOutput(entry parser.Entry, s *sdk.SDK, state *types.ServerState, serverReady chan struct{}, cancel context.CancelFunc) {
+	ctx, cancelTimeout := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelTimeout()
 
 	defer func() {
 		if r := recover(); r != nil {
@@ -35,16 +41,15 @@ func HandleServerOutput(output string, s *sdk.SDK, state *types.ServerState, ser
 		}
 	}()
 
-	// Validate input length to prevent excessive memory usage
-	if len(output) > 8192 { // Limit input size
-		utils.LogWarning("Large output received (%d bytes)", len(output))
-		output = output[:8192]
-	}
-
+	output := strings.TrimSpace(entry.Raw)
 	if output == "" {
 		return
 	}
 
+	state.Lock()
+	state.LastOutput = time.Now()
+	state.Unlock()
+
 	// Common labels for all metrics
 	baseLabels := prometheus.Labels{
 		"server_id":   state.ServerID,
@@ -57,102 +62,32 @@ func HandleServerOutput(output string, s *sdk.SDK, state *types.ServerState, ser
 		utils.LogWarning("Timeout while processing server output")
 		return
 	default:
-		switch {
-		case strings.Contains(output, "is attempting to connect"):
-			handleAttemptingToConnect(output, state, baseLabels)
-		case strings.Contains(output, "supports extra CSP features"):
-			handleExtraCSPFeatures(output, state, baseLabels)
-		case strings.Contains(output, "Starting Assetto Corsa Server..."):
-			handleServerStarting(state, baseLabels)
-		case strings.Contains(output, "Lobby registration successful"):
-			handleServerReady(state, baseLabels, serverReady)
-		case strings.Contains(output, "End of session"):
-			handleSessionEnd(s, state, baseLabels, cancel)
-		case strings.Contains(output, "has connected"):
-			handlePlayerConnect(s, state, output, baseLabels)
-		case strings.Contains(output, "has disconnected"):
-			handlePlayerDisconnect(s, state, output, baseLabels)
-		case strings.Contains(output, "Next session:"):
-			handleSessionChange(state, output, baseLabels)
-		case strings.Contains(output, "[ERR]"):
-			handleError(fmt.Errorf(output), "server_error", state, baseLabels)
-		case strings.Contains(output, "Steam authentication succeeded"):
-			handleSteamAuth(state, baseLabels)
-		case strings.Contains(output, "Network stats"):
-			handleNetworkStats(output, baseLabels)
-		case strings.Contains(output, "steamclient.so") || strings.Contains(output, "SteamAPI"):
-			handleSteamError(output, state, baseLabels)
-		case strings.Contains(output, "AssettoServer"):
-			handleServerVersion(output, state, baseLabels)
-		case strings.Contains(output, "Loading") && strings.Contains(output, ".ini"):
-			handleConfigLoading(output, state, baseLabels)
-		case strings.Contains(output, "Loaded plugin"):
-			handlePluginLoading(output, state, baseLabels)
-		case strings.Contains(output, "AI Slot"):
-			handleAISlotUpdate(output, state, baseLabels)
-		case strings.Contains(output, "Added checksum"):
-			handleChecksumUpdate(output, state, baseLabels)
-		case strings.Contains(output, "Server invite link:"):
-			handleServerInvite(output, state, baseLabels)
-		case strings.Contains(output, "Switching session to id"):
-			handleSessionSwitch(output, state, baseLabels)
-		case strings.Contains(output, "Starting TCP server"):
-			handleTCPServer(output, state, baseLabels)
-		case strings.Contains(output, "Starting UDP server"):
-			handleUDPServer(output, state, baseLabels)
-		case strings.Contains(output, "Remaining time of session"):
-			handleSessionTime(output, state, baseLabels)
-		case strings.Contains(output, "Registering server to lobby"):
-			handleLobbyRegistration(output, state, baseLabels)
-		case strings.Contains(output, "Starting update loop"):
-			handleUpdateLoop(output, state, baseLabels)
-		case strings.Contains(output, "Lobby registration successful"):
-			handleLobbySuccess(output, state, baseLabels)
-		case strings.Contains(output, "Loading extra_cfg.yml"):
-			handleConfigLoading(output, state, baseLabels)
-		case strings.Contains(output, "Using minimum required CSP Version"):
-			handleCSPVersion(output, state, baseLabels)
-		case strings.Contains(output, "Cached AI spline"):
-			handleAISpline(output, state, baseLabels)
-		case strings.Contains(output, "Adjacent lane detection"):
-			handleAILaneDetection(output, state, baseLabels)
-		case strings.Contains(output, "Writing cached AI spline"):
-			handleAISplineCache(output, state, baseLabels)
-		case strings.Contains(output, "Mapping cached AI spline"):
-			handleAISplineMapping(output, state, baseLabels)
-		case strings.Contains(output, "Storing keys in a directory"):
-			handleKeysStorage(output, state, baseLabels)
-		case strings.Contains(output, "No XML encryptor configured"):
-			handleXMLEncryption(output, state, baseLabels)
-		case strings.Contains(output, "Loaded blacklist.txt"):
-			handleBlacklistLoading(output, state, baseLabels)
-		case strings.Contains(output, "Loaded whitelist.txt"):
-			handleWhitelistLoading(output, state, baseLabels)
-		case strings.Contains(output, "Loaded admins.txt"):
-			handleAdminsLoading(output, state, baseLabels)
-		case strings.Contains(output, "Connected to Steam Servers"):
-			handleSteamConnection(output, state, baseLabels)
-		case strings.Contains(output, "CSP handshake received"):
-			handleCSPHandshake(output, state, baseLabels)
-		case strings.Contains(output, "CHAT:"):
-			handleChatMessage(output, state, baseLabels)
-		case strings.Contains(output, "Received clean exit"):
-			handleCleanExit(output, state, baseLabels)
-		default:
-			utils.LogWarning("Unhandled output: %s", output)
+		levelLabels := copyLabels(baseLabels)
+		levelLabels["level"] = entry.Level
+		metrics.ServerOutputLinesCounter.With(levelLabels).Inc()
+
+		matches := ruleEngine.Match(output)
+		if len(matches) == 0 {
+			// Continuation lines (e.g. stack traces) carry no prefix and are expected to be unmatched
+			if entry.Level == "" {
+				utils.LogDebug("Unhandled output: %s", output)
+			} else {
+				utils.LogWarning("Unhandled output: %s", output)
+			}
+			return
 		}
-	}
-}
-
-// StartNewSession initiates a new game session with the specified type and track.
-func StartNewSession(state *types.ServerState, sessionType, track string) {
-	state.Lock()
-	defer state.Unlock()
 
-	state.CurrentSession = &types.Session{
-		Type:      sessionType,
-		StartTime: time.Now(),
-		Track:     track,
+		c := &outputContext{
+			entry:       entry,
+			sdk:         s,
+			state:       state,
+			serverReady: serverReady,
+			cancel:      cancel,
+			labels:      baseLabels,
+		}
+		for _, match := range matches {
+			applyRule(c, match)
+		}
 	}
 }
 
@@ -163,8 +98,8 @@ func handleServerStarting(state *types.ServerState, labels prometheus.Labels) {
 	state.Ready = false
 	state.ShuttingDown = false
 	state.Unlock()
+	// Starts are counted by the process supervisor
 	metrics.ServerStateGauge.With(labels).Set(types.ServerStateStarting)
-	metrics.ServerStartCounter.With(labels).Inc()
 }
 
 // handleServerReady updates the server state to ready and signals readiness.
@@ -188,39 +123,62 @@ func handleServerReady(state *types.ServerState, labels prometheus.Labels, serve
 }
 
 // handleSessionEnd handles the end of a game session by kicking all players and initiating a graceful shutdown.
+// The server only shuts down if the end-of-session policy says so.
 func handleSessionEnd(s *sdk.SDK, state *types.ServerState, labels prometheus.Labels, cancel context.CancelFunc) {
+	state.RLock()
+	shuttingDown := state.ShuttingDown
+	state.RUnlock()
+	if shuttingDown {
+		return
+	}
+
+	metrics.SessionEndCounter.With(labels).Inc()
+	sessionManager.EndCurrentSession()
+
+	shutdown, reason := sessionManager.ShouldShutdown(sessionEndConfig)
+	if !shutdown {
+		utils.LogSDK("Session ended, keeping the server running (%s policy)", sessionEndConfig.Policy)
+		return
+	}
+	endServer(s, state, labels, cancel, reason)
+}
+
+// endServer disconnects every player and shuts the server down at the end of a session.
+func endServer(s *sdk.SDK, state *types.ServerState, labels prometheus.Labels, cancel context.CancelFunc, reason string) {
 	state.Lock()
 	if state.ShuttingDown {
 		state.Unlock()
 		return
 	}
 	state.ShuttingDown = true
-
-	// Clear connected players on session end
-	for steamID, player := range state.ConnectedPlayers {
-		utils.LogSDK("Player %s (Steam ID: %s) disconnected due to session end", player.Name, steamID)
-		delete(state.ConnectedPlayers, steamID)
-	}
-	state.Players = 0
 	state.Unlock()
 
-	utils.LogSDK("Session ended, initiating server shutdown")
+	disconnectAll(s, state, labels, "session end")
+	utils.LogSDK("Session ended (%s), initiating server shutdown", reason)
 	metrics.ServerStateGauge.With(labels).Set(types.ServerStateShutdown)
-	metrics.SessionEndCounter.With(labels).Inc()
-	metrics.PlayersGauge.With(labels).Set(0) // Reset player count to 0
 	gracefulShutdown(s, cancel, state)
 }
 
 // handlePlayerConnect processes a player's connection, updates player counts, and increments relevant metrics.
-func handlePlayerConnect(s *sdk.SDK, state *types.ServerState, output string, labels prometheus.Labels) {
-	// Extract player info using the utility function
-	player := utils.ExtractPlayerInfo(output)
+func handlePlayerConnect(s *sdk.SDK, state *types.ServerState, entry parser.Entry, labels prometheus.Labels) {
+	// Extract player info from the event properties or the output text
+	player := playerFromEntry(entry)
 	if player.SteamID == "" {
-		utils.LogWarning("Invalid player info from output: %s", output)
+		utils.LogWarning("Invalid player info from output: %s", entry.Raw)
 		return
 	}
 
 	addPlayer(state, player)
+	sessionManager.AddParticipant(player)
+	playerTracker.Connect(player.SteamID)
+	playerCounters.Update(state)
+	webhooks.Publish(webhook.Event{
+		LogEvent: types.LogEvent{
+			Event:   webhook.EventPlayerJoin,
+			Message: fmt.Sprintf("Player %s joined", player.Name),
+		},
+		Player: &player,
+	})
 
 	// Update basic metrics with base labels
 	metrics.PlayersGauge.With(labels).Set(float64(state.Players))
@@ -244,46 +202,206 @@ func handlePlayerConnect(s *sdk.SDK, state *types.ServerState, output string, la
 }
 
 // handlePlayerDisconnect processes a player's disconnection and updates relevant metrics.
-func handlePlayerDisconnect(s *sdk.SDK, state *types.ServerState, output string, labels prometheus.Labels) {
-	steamID := utils.ExtractSteamID(output)
-	removePlayer(state, steamID)
+func handlePlayerDisconnect(s *sdk.SDK, state *types.ServerState, entry parser.Entry, labels prometheus.Labels) {
+	steamID, name := disconnectedPlayerFromEntry(entry)
+	metrics.PlayerDisconnectCounter.With(labels).Inc()
 
-	metrics.PlayersGauge.With(labels).Set(float64(state.Players))
+	player, ok := removePlayer(state, steamID, name)
+	if !ok {
+		utils.LogWarning("Disconnect of unknown player: %s", entry.Raw)
+		return
+	}
+
+	playerLeft(s, state, labels, player)
+	utils.LogSDK("Player disconnected: %s (%s)", player.Name, player.SteamID)
+}
+
+// DisconnectPlayer disconnects a connected player whose disconnection the server output missed,
+// reporting it as handlePlayerDisconnect does. It returns false if the player is not connected.
+func DisconnectPlayer(s *sdk.SDK, state *types.ServerState, steamID, reason string) bool {
+	state.RLock()
+	labels := prometheus.Labels{
+		"server_id":   state.ServerID,
+		"server_name": state.ServerName,
+		"server_type": state.ServerType,
+	}
+	state.RUnlock()
+
+	player, ok := removePlayer(state, steamID, "")
+	if !ok {
+		return false
+	}
 	metrics.PlayerDisconnectCounter.With(labels).Inc()
-	updatePlayerCount(s, state.Players)
+	playerLeft(s, state, labels, player)
+	utils.LogSDK("Player %s (Steam ID: %s) disconnected due to %s", player.Name, player.SteamID, reason)
+	return true
+}
+
+// DisconnectProcessPlayers disconnects every connected player when the server process exits,
+// and ends the current session they took part in.
+func DisconnectProcessPlayers(s *sdk.SDK, state *types.ServerState) {
+	state.RLock()
+	labels := prometheus.Labels{
+		"server_id":   state.ServerID,
+		"server_name": state.ServerName,
+		"server_type": state.ServerType,
+	}
+	state.RUnlock()
 
-	utils.LogSDK("Player disconnected: %s", steamID)
+	disconnectAll(s, state, labels, "server process exit")
+	sessionManager.EndCurrentSession()
 }
 
-// handleSessionChange manages changes to the game session, such as switching tracks or session types.
-func handleSessionChange(state *types.ServerState, output string, labels prometheus.Labels) {
-	logEvent("SESSION_CHANGE", "Session change detected", state)
-	sessionType := utils.ExtractSessionType(output)
-	track := utils.ExtractTrackName(output)
+// disconnectAll removes every connected player, reporting each one as a disconnection.
+func disconnectAll(s *sdk.SDK, state *types.ServerState, labels prometheus.Labels, reason string) {
+	state.Lock()
+	players := make([]*types.Player, 0, len(state.ConnectedPlayers))
+	for steamID, player := range state.ConnectedPlayers {
+		players = append(players, player)
+		delete(state.ConnectedPlayers, steamID)
+	}
+	state.Players = 0
+	state.Unlock()
+
+	for _, player := range players {
+		utils.LogSDK("Player %s (Steam ID: %s) disconnected due to %s", player.Name, player.SteamID, reason)
+		playerLeft(s, state, labels, player)
+	}
+	if len(players) == 0 {
+		metrics.PlayersGauge.With(labels).Set(0)
+	}
+}
+
+// playerLeft reports a player removed from the state to Agones and the metrics.
+func playerLeft(s *sdk.SDK, state *types.ServerState, labels prometheus.Labels, player *types.Player) {
+	playerTracker.Disconnect(player.SteamID)
+	playerCounters.Update(state)
+
+	state.RLock()
+	count := state.Players
+	state.RUnlock()
+	metrics.PlayersGauge.With(labels).Set(float64(count))
+	updatePlayerCount(s, count)
+}
 
-	if sessionType == "" || track == "" {
-		utils.LogWarning("Invalid session info from output: %s", output)
+// handleLapCompleted records a lap completed by a connected player in the player's state and
+// the current session, and updates the lap metrics.
+func handleLapCompleted(state *types.ServerState, entry parser.Entry, labels prometheus.Labels) {
+	name, cuts, lapTime := lapFromEntry(entry)
+	if name == "" || lapTime <= 0 {
+		utils.LogWarning("Invalid lap info from output: %s", entry.Raw)
 		return
 	}
 
+	// Lap completions only identify the driver by name
 	state.Lock()
-	oldSession := state.CurrentSession
+	var player *types.Player
+	for _, p := range state.ConnectedPlayers {
+		if p.Name == name {
+			player = p
+			break
+		}
+	}
+	if player == nil {
+		state.Unlock()
+		utils.LogWarning("Lap completed by unknown player: %s", entry.Raw)
+		return
+	}
+	player.Laps++
+	player.LastLap = lapTime
+	if cuts == 0 && (player.BestLap == 0 || lapTime < player.BestLap) {
+		player.BestLap = lapTime
+	}
+	lap := types.Lap{
+		SteamID:   player.SteamID,
+		Name:      player.Name,
+		CarModel:  player.CarModel,
+		LapTime:   lapTime,
+		Cuts:      cuts,
+		Timestamp: time.Now(),
+	}
+	bestLap, laps := player.BestLap, player.Laps
+	track := state.CurrentTrack
+	if state.CurrentSession != nil && state.CurrentSession.Track != "" {
+		track = state.CurrentSession.Track
+	}
 	state.Unlock()
 
-	StartNewSession(state, sessionType, track)
+	position := sessionManager.RecordLap(lap)
+
+	lapLabels := copyLabels(labels)
+	lapLabels["track"] = track
+	lapLabels["car_model"] = lap.CarModel
+	metrics.LapTimeHistogram.With(lapLabels).Observe(float64(lapTime) / 1000)
+
+	if bestLap > 0 {
+		playerLabels := copyLabels(labels)
+		playerLabels["player_name"] = lap.Name
+		playerLabels["steam_id"] = lap.SteamID
+		metrics.PlayerBestLapGauge.With(playerLabels).Set(float64(bestLap))
+	}
+
+	utils.LogEvent("LAP", "Lap completed by %s (%s): %s, %d cuts, lap %d, P%d",
+		lap.Name, lap.SteamID, formatLapTime(lapTime), cuts, laps, position)
+}
+
+// formatLapTime formats a lap time in milliseconds as m:ss.fff.
+func formatLapTime(ms int64) string {
+	return fmt.Sprintf("%d:%02d.%03d", ms/60000, ms/1000%60, ms%1000)
+}
+
+// handleSessionChange manages changes to the game session, such as switching tracks or session types.
+// Sessions ended by the transition are evaluated against the end-of-session policy.
+func handleSessionChange(s *sdk.SDK, state *types.ServerState, entry parser.Entry, labels prometheus.Labels, cancel context.CancelFunc) {
+	utils.LogEvent("SESSION_CHANGE", "Session change detected")
+	sessionType, track := sessionFromEntry(entry)
+
+	if sessionType == "" {
+		utils.LogWarning("Invalid session info from output: %s", entry.Raw)
+		return
+	}
+	layout := ""
+	if track == "" {
+		// AssettoServer does not log the track with the session change: use the one reported
+		// by the server API, which also fills it in later if it is not known yet
+		state.RLock()
+		track, layout = state.CurrentTrack, state.CurrentLayout
+		state.RUnlock()
+	}
+
+	// Session durations and changes are recorded by the session transition subscribers
+	previous := sessionManager.GetCurrentSession()
+	sessionManager.StartSession(types.Session{
+		Type:   sessionType,
+		Track:  track,
+		Layout: layout,
+		Length: sessionLengthFromEntry(entry),
+	})
+
+	// Players still connected take part in the new session, starting without laps
+	state.Lock()
+	connected := make([]types.Player, 0, len(state.ConnectedPlayers))
+	for _, player := range state.ConnectedPlayers {
+		player.BestLap, player.LastLap, player.Laps = 0, 0, 0
+		connected = append(connected, *player)
+	}
+	state.Unlock()
+	for _, player := range connected {
+		sessionManager.AddParticipant(player)
+	}
+	metrics.PlayerBestLapGauge.Reset()
 
-	if oldSession != nil {
-		sessionDuration := time.Since(oldSession.StartTime)
-		metrics.SessionDurationHistogram.With(prometheus.Labels{
-			"session_type": oldSession.Type,
-			"track":        oldSession.Track,
-		}).Observe(sessionDuration.Seconds())
+	if track != "" {
+		trackLabels := copyLabels(labels)
+		trackLabels["track_name"] = track
+		metrics.TrackUsageCounter.With(trackLabels).Inc()
 	}
 
-	metrics.SessionChangeCounter.With(labels).Inc()
-	trackLabels := copyLabels(labels)
-	trackLabels["track_name"] = track
-	metrics.TrackUsageCounter.With(trackLabels).Inc()
+	if previous != nil && sessionEndConfig.Transitions() {
+		if shutdown, reason := sessionManager.ShouldShutdown(sessionEndConfig); shutdown {
+			endServer(s, state, labels, cancel, reason)
+		}
+	}
 }
 
 // handleSteamAuth records successful Steam authentication events.
@@ -292,6 +410,16 @@ func handleSteamAuth(_ *types.ServerState, labels prometheus.Labels) {
 	metrics.AuthSuccessCounter.With(labels).Inc()
 }
 
+// handleModeration records a moderation event of the given type to the moderation feed.
+func handleModeration(entry parser.Entry, eventType string) {
+	event := moderationFromEntry(entry, eventType)
+	if moderationFeed == nil {
+		utils.LogEvent("MODERATION", "%s: %s (%s)", eventType, event.Name, event.SteamID)
+		return
+	}
+	moderationFeed.Record(event)
+}
+
 // handleNetworkStats updates network-related metrics based on the server output.
 func handleNetworkStats(output string, labels prometheus.Labels) {
 	if bytesReceived := utils.ExtractBytesReceived(output); bytesReceived > 0 {
@@ -330,19 +458,52 @@ func updatePlayerCount(s *sdk.SDK, count int) {
 	}
 }
 
-// logEvent logs an event with contextual information about the server state.
-func logEvent(eventType string, message string, state *types.ServerState) {
-	sessionType := "unknown"
-	if state.CurrentSession != nil {
-		sessionType = state.CurrentSession.Type
-	}
+// playerTracker reports player connections to Agones, nil when player tracking is disabled.
+var playerTracker *players.Tracker
+
+// playerCounters maintains the Agones counters and lists, nil when they are disabled.
+var playerCounters *players.Counters
 
-	utils.LogSDK("[%s] %s | Server: %s | Players: %d | Session: %s",
-		eventType,
-		message,
-		state.ServerName,
-		state.Players,
-		sessionType)
+// SetPlayerTracker enables Agones player tracking of the connecting and disconnecting players.
+func SetPlayerTracker(tracker *players.Tracker) {
+	playerTracker = tracker
+}
+
+// SetPlayerCounters enables the update of the Agones counters and lists on player and AI slot events.
+func SetPlayerCounters(counters *players.Counters) {
+	playerCounters = counters
+}
+
+// moderationFeed records the moderation events, nil to only log them.
+var moderationFeed *moderation.Feed
+
+// SetModerationFeed sets the feed recording the moderation events.
+func SetModerationFeed(feed *moderation.Feed) {
+	moderationFeed = feed
+}
+
+// webhooks posts the lifecycle and player events, nil when no webhook is configured.
+var webhooks *webhook.Dispatcher
+
+// SetWebhookDispatcher sets the dispatcher posting the player events to the webhooks.
+func SetWebhookDispatcher(dispatcher *webhook.Dispatcher) {
+	webhooks = dispatcher
+}
+
+// sessionManager tracks the sessions, replaced by SetSessionManager.
+var sessionManager = session.NewSessionManager(100)
+
+// SetSessionManager sets the manager the session events are recorded in.
+func SetSessionManager(sessions *session.SessionManager) {
+	sessionManager = sessions
+}
+
+// sessionEndConfig is the end-of-session policy.
+var sessionEndConfig = session.EndConfig{Policy: session.EndPolicyShutdown}
+
+// SetSessionEndPolicy sets the policy applied when a session ends.
+func SetSessionEndPolicy(config session.EndConfig) {
+	sessionEndConfig = config
 }
 
 // addPlayer adds a new player to the server's state and increments the player count.
@@ -350,19 +511,36 @@ func addPlayer(state *types.ServerState, player types.Player) {
 	state.Lock()
 	defer state.Unlock()
 
+	player.ConnectedAt = time.Now()
 	state.ConnectedPlayers[player.SteamID] = &player
 	state.Players++
 }
 
 // removePlayer removes a player from the server's state and decrements the player count.
-func removePlayer(state *types.ServerState, steamID string) {
+// The player is looked up by Steam ID or, when it is unknown, by name.
+// It returns false if no such player is connected.
+func removePlayer(state *types.ServerState, steamID, name string) (*types.Player, bool) {
 	state.Lock()
 	defer state.Unlock()
 
+	player, ok := state.ConnectedPlayers[steamID]
+	if !ok && name != "" {
+		for id, p := range state.ConnectedPlayers {
+			if p.Name == name {
+				steamID, player, ok = id, p, true
+				break
+			}
+		}
+	}
+	if !ok {
+		return nil, false
+	}
+
 	delete(state.ConnectedPlayers, steamID)
 	if state.Players > 0 {
 		state.Players--
 	}
+	return player, true
 }
 
 // gracefulShutdown performs a graceful shutdown of the server by updating the state and notifying the SDK.
@@ -375,7 +553,9 @@ func gracefulShutdown(s *sdk.SDK, cancel context.CancelFunc, state *types.Server
 		utils.LogWarning("Could not send shutdown message: %v", err)
 	}
 	time.Sleep(time.Second)
-	cancel()
+	if cancel != nil {
+		cancel()
+	}
 
 	utils.LogSDK("Server shutdown initiated")
 }
@@ -411,12 +591,13 @@ func handlePluginLoading(output string, _ *types.ServerState, _ prometheus.Label
 }
 
 // handleAISlotUpdate handles server AI slot update-related events and updates metrics accordingly.
-func handleAISlotUpdate(output string, state *types.ServerState, labels prometheus.Labels) {
+func handleAISlotUpdate(entry parser.Entry, state *types.ServerState, labels prometheus.Labels) {
 	// Extract AI slot information
-	slots := utils.ExtractAISlots(output)
+	slots := aiSlotsFromEntry(entry)
 	state.Lock()
 	state.ActiveCars = slots
 	state.Unlock()
+	playerCounters.Update(state)
 
 	// Ensure all required labels are present
 	aiLabels := prometheus.Labels{
@@ -469,11 +650,9 @@ func handleServerInvite(output string, _ *types.ServerState, _ prometheus.Labels
 func handleSessionSwitch(output string, state *types.ServerState, _ prometheus.Labels) {
 	sessionID := extractSessionID(output)
 	//utils.LogSDK("Switching to session ID: %s", sessionID)
-	state.Lock()
-	if state.CurrentSession != nil {
-		state.CurrentSession.ID = sessionID
-	}
-	state.Unlock()
+	sessionManager.UpdateCurrentSession(func(session *types.Session) {
+		session.ID = sessionID
+	})
 }
 
 // handleTCPServer handles TCP server-related events
@@ -500,11 +679,9 @@ func handleUDPServer(output string, _ *types.ServerState, _ prometheus.Labels) {
 func handleSessionTime(output string, state *types.ServerState, _ prometheus.Labels) {
 	duration := strings.Split(output, "session :")[1]
 	//utils.LogSDK("Remaining time of session :%s", duration)
-	state.Lock()
-	if state.CurrentSession != nil {
-		state.CurrentSession.RemainingTime = strings.TrimSpace(duration)
-	}
-	state.Unlock()
+	sessionManager.UpdateCurrentSession(func(session *types.Session) {
+		session.RemainingTime = strings.TrimSpace(duration)
+	})
 }
 
 // handleLobbyRegistration handles lobby registration-related events
@@ -513,7 +690,11 @@ func handleLobbyRegistration(_ string, _ *types.ServerState, _ prometheus.Labels
 }
 
 // handleUpdateLoop handles update loop-related events
-func handleUpdateLoop(output string, _ *types.ServerState, labels prometheus.Labels) {
+func handleUpdateLoop(output string, state *types.ServerState, labels prometheus.Labels) {
+	state.Lock()
+	state.UpdateLoopStarted = true
+	state.Unlock()
+
 	rate := strings.Split(output, "rate of")[1]
 	metrics.ServerUpdateRateGauge.With(labels).Set(parseUpdateRate(rate))
 }
@@ -575,19 +756,19 @@ func handleXMLEncryption(output string, _ *types.ServerState, _ prometheus.Label
 	utils.LogWarning(output)
 }
 
-// handleBlacklistLoading handles blacklist loading events
+// handleBlacklistLoading logs the loading of the blacklist, for the moderation audit trail
 func handleBlacklistLoading(output string, _ *types.ServerState, _ prometheus.Labels) {
-	// Don't log anything
+	utils.LogSDK("%s", output)
 }
 
-// handleWhitelistLoading handles whitelist loading events
+// handleWhitelistLoading logs the loading of the whitelist, for the moderation audit trail
 func handleWhitelistLoading(output string, _ *types.ServerState, _ prometheus.Labels) {
-	// Don't log anything
+	utils.LogSDK("%s", output)
 }
 
-// handleAdminsLoading handles admin list loading events
+// handleAdminsLoading logs the loading of the admin list, for the moderation audit trail
 func handleAdminsLoading(output string, _ *types.ServerState, _ prometheus.Labels) {
-	// Don't log anything
+	utils.LogSDK("%s", output)
 }
 
 // handleSteamConnection handles Steam connection events
@@ -603,10 +784,9 @@ func handleExtraCSPFeatures(output string, _ *types.ServerState, _ prometheus.La
 	// Don't log anything
 }
 
-func handleCSPHandshake(output string, state *types.ServerState, labels prometheus.Labels) {
-	if strings.Contains(output, "Version=") {
-		version := utils.ExtractCSPVersion(output)
-		playerName := utils.ExtractCSPPlayerName(output)
+func handleCSPHandshake(entry parser.Entry, state *types.ServerState, labels prometheus.Labels) {
+	if strings.Contains(entry.Raw, "Version=") {
+		version, playerName := cspHandshakeFromEntry(entry)
 
 		// S'assurer que tous les labels requis sont présents
 		cspLabels := prometheus.Labels{
@@ -625,7 +805,7 @@ func handleChatMessage(_ string, _ *types.ServerState, labels prometheus.Labels)
 	metrics.ChatMessagesCounter.With(labels).Inc()
 }
 
-func handleCleanExit(output string, _ *types.ServerState, _ prometheus.Labels) {
-	steamID := utils.ExtractSteamID(output)
+func handleCleanExit(entry parser.Entry, _ *types.ServerState, _ prometheus.Labels) {
+	steamID := steamIDFromEntry(entry)
 	utils.LogDebug("Clean exit received for player with Steam ID: %s", steamID)
 }
@@ -0,0 +1,150 @@
+// Package lifecycle starts the components of the wrapper in order and stops them in reverse order.
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+
+	"agones/utils"
+)
+
+// Component is a part of the wrapper managed by an Orchestrator.
+type Component struct {
+	Name  string                          // Name used in logs
+	Start func(ctx context.Context) error // Starts the component, must not block while it runs
+	Stop  func(ctx context.Context) error // Stops the component, may be nil
+}
+
+// Orchestrator starts components in the order they were added and stops them in reverse order.
+type Orchestrator struct {
+	mu         sync.Mutex
+	components []Component
+	started    []Component
+}
+
+// NewOrchestrator creates an empty Orchestrator.
+func NewOrchestrator() *Orchestrator {
+	return &Orchestrator{}
+}
+
+// Add appends a component, started after the ones already added.
+func (o *Orchestrator) Add(components ...Component) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.components = append(o.components, components...)
+}
+
+// Start starts the components in order. If a component fails to start, the components
+// already started are stopped and the error is returned.
+func (o *Orchestrator) Start(ctx context.Context) error {
+	o.mu.Lock()
+	components := o.components
+	o.mu.Unlock()
+
+	for _, c := range components {
+		utils.LogSDK("Starting %s", c.Name)
+		if err := c.Start(ctx); err != nil {
+			o.Stop(ctx)
+			return fmt.Errorf("failed to start %s: %v", c.Name, err)
+		}
+
+		o.mu.Lock()
+		o.started = append(o.started, c)
+		o.mu.Unlock()
+	}
+	return nil
+}
+
+// Stop stops the started components in reverse order. Errors are logged and do not
+// prevent the remaining components from stopping.
+func (o *Orchestrator) Stop(ctx context.Context) {
+	o.mu.Lock()
+	started := o.started
+	o.started = nil
+	o.mu.Unlock()
+
+	for i := len(started) - 1; i >= 0; i-- {
+		c := started[i]
+		if c.Stop == nil {
+			continue
+		}
+		utils.LogSDK("Stopping %s", c.Name)
+		if err := c.Stop(ctx); err != nil {
+			utils.LogWarning("Failed to stop %s: %v", c.Name, err)
+		}
+	}
+}
+
+// HTTPServer returns a component serving server. The listener is opened when the component
+// starts, so that a port conflict fails the startup, and the server is shut down gracefully.
+func HTTPServer(name string, server *http.Server) Component {
+	return Component{
+		Name: name,
+		Start: func(_ context.Context) error {
+			listener, err := net.Listen("tcp", server.Addr)
+			if err != nil {
+				return err
+			}
+			go func() {
+				if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+					utils.LogError("%s error: %v", name, err)
+				}
+			}()
+			return nil
+		},
+		Stop: server.Shutdown,
+	}
+}
+
+// Go returns a component running run in a goroutine. Stopping it cancels the context
+// passed to run and waits for run to return. A stopped component can be started again,
+// starting it while run has not returned fails.
+func Go(name string, run func(ctx context.Context)) Component {
+	var (
+		mu     sync.Mutex
+		cancel context.CancelFunc
+		done   chan struct{}
+	)
+
+	return Component{
+		Name: name,
+		Start: func(ctx context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if done != nil {
+				select {
+				case <-done:
+				default:
+					return fmt.Errorf("%s is already running", name)
+				}
+			}
+
+			ctx, cancel = context.WithCancel(ctx)
+			done = make(chan struct{})
+			go func(done chan struct{}) {
+				defer close(done)
+				run(ctx)
+			}(done)
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			mu.Lock()
+			stop, stopped := cancel, done
+			mu.Unlock()
+			if stop == nil {
+				return nil
+			}
+
+			stop()
+			select {
+			case <-stopped:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+	}
+}
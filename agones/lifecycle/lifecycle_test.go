@@ -0,0 +1,102 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// recorder records the start and stop of components.
+type recorder []string
+
+func (r *recorder) component(name string, startErr error) Component {
+	return Component{
+		Name: name,
+		Start: func(context.Context) error {
+			*r = append(*r, "start "+name)
+			return startErr
+		},
+		Stop: func(context.Context) error {
+			*r = append(*r, "stop "+name)
+			return nil
+		},
+	}
+}
+
+func TestOrchestrator(t *testing.T) {
+	var events recorder
+	o := NewOrchestrator()
+	o.Add(events.component("a", nil), events.component("b", nil))
+	o.Add(events.component("c", nil))
+
+	if err := o.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	o.Stop(context.Background())
+	o.Stop(context.Background())
+
+	want := recorder{"start a", "start b", "start c", "stop c", "stop b", "stop a"}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %q, want %q", events, want)
+	}
+}
+
+func TestOrchestratorStartFailure(t *testing.T) {
+	var events recorder
+	o := NewOrchestrator()
+	o.Add(events.component("a", nil), events.component("b", errors.New("port in use")), events.component("c", nil))
+
+	if err := o.Start(context.Background()); err == nil || err.Error() != "failed to start b: port in use" {
+		t.Fatalf("Start = %v, want the failure of b", err)
+	}
+	want := recorder{"start a", "start b", "stop a"}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %q, want %q", events, want)
+	}
+}
+
+func TestGo(t *testing.T) {
+	runs := make(chan context.Context, 2)
+	c := Go("worker", func(ctx context.Context) {
+		runs <- ctx
+		<-ctx.Done()
+	})
+
+	if err := c.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop before Start: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := c.Start(context.Background()); err != nil {
+			t.Fatalf("Start #%d: %v", i+1, err)
+		}
+		ctx := <-runs
+		if i == 0 {
+			if err := c.Start(context.Background()); err == nil {
+				t.Error("Start while running succeeded")
+			}
+		}
+		if err := c.Stop(context.Background()); err != nil {
+			t.Fatalf("Stop #%d: %v", i+1, err)
+		}
+		if ctx.Err() == nil {
+			t.Errorf("run #%d context not cancelled by Stop", i+1)
+		}
+	}
+}
+
+func TestGoStopTimeout(t *testing.T) {
+	release := make(chan struct{})
+	c := Go("stuck", func(context.Context) { <-release })
+	defer close(release)
+
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.Stop(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Stop = %v, want context.Canceled", err)
+	}
+}
@@ -4,27 +4,51 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	sdk "agones.dev/agones/sdks/go"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
+	"agones/acapi"
+	"agones/admin"
+	"agones/allocation"
+	"agones/config"
+	"agones/console"
+	"agones/drain"
 	"agones/handlers"
+	"agones/lifecycle"
+	"agones/moderation"
 	"agones/monitoring"
+	"agones/parser"
+	"agones/players"
+	"agones/results"
+	"agones/scheduler"
+	"agones/serverconfig"
+	"agones/session"
+	"agones/supervisor"
 	"agones/types"
 	"agones/utils"
+	"agones/webhook"
 )
 
+// restartResetAfter is the run time after which the consecutive restart count is reset.
+const restartResetAfter = 10 * time.Minute
+
+// componentStopTimeout bounds the time spent stopping the wrapper components. It leaves the
+// game server process the time to exit on SIGTERM before the supervisor kills it.
+const componentStopTimeout = supervisor.StopTimeout + 5*time.Second
+
 // interceptor implémente un io.Writer qui intercepte et transmet les données écrites
 type interceptor struct {
 	forward   io.Writer
@@ -42,12 +66,30 @@ func (i *interceptor) Write(p []byte) (n int, err error) {
 // It initializes the Agones SDK, starts the Assetto Corsa server,
 // and manages the server's lifecycle including health checks and metrics.
 func main() {
-	// Command line flags
-	input := flag.String("i", "./start-server.sh", "Path to server start script")
-	args := flag.String("args", "", "Arguments for the server")
-	shutdownTimeout := flag.Duration("shutdown-timeout", 8*time.Second, "Shutdown timeout")
-	reserveDuration := flag.Duration("reserve-duration", 10*time.Minute, "Duration for server reservation")
-	flag.Parse()
+	// Load the configuration from the defaults, file, environment and flags
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		utils.LogError("Invalid configuration: %v", err)
+		os.Exit(2)
+	}
+
+	utils.SetLogOutput(cfg.LogFormat)
+	utils.SetDebug(cfg.Debug)
+	format, _ := parser.ParseFormat(cfg.InputFormat)
+
+	endPolicy, _ := session.ParseEndPolicy(cfg.SessionEndPolicy)
+	handlers.SetSessionEndPolicy(session.EndConfig{
+		Policy:    endPolicy,
+		Sessions:  cfg.SessionEndCount,
+		WallClock: cfg.SessionEndAfter,
+	})
+
+	// Load custom output rules on top of the built-in ones
+	if cfg.RulesFile != "" {
+		if err := handlers.LoadRules(cfg.RulesFile); err != nil {
+			utils.LogError("Failed to load output rules, using built-in rules: %v", err)
+		}
+	}
 
 	// Create the SDK instance
 	s, err := sdk.NewSDK()
@@ -65,42 +107,325 @@ func main() {
 			Type: "initializing",
 		},
 	}
+	utils.SetLogContextProvider(serverState.LogContext)
+
+	// The session manager owns the sessions and mirrors the current one into the state
+	sessions := session.NewSessionManager(100)
+	sessions.Mirror(serverState)
+	// Admin commands reach the server through its RCON listener, nil when it is disabled
+	var commands *console.Console
+	if cfg.RconPort != 0 {
+		commands = console.New(serverState, utils.NewRateLimiter(), console.Config{
+			Address:  fmt.Sprintf("127.0.0.1:%d", cfg.RconPort),
+			Password: cfg.RconPassword,
+		})
+	}
+	if cfg.SessionStore != "" {
+		if err := sessions.SetStore(session.NewFileStore(cfg.SessionStore)); err != nil {
+			utils.LogWarning("Failed to load session history from %s: %v", cfg.SessionStore, err)
+		} else {
+			utils.LogSDK("Loaded %d sessions from %s", len(sessions.GetSessionHistory()), cfg.SessionStore)
+		}
+	}
+	handlers.SetSessionManager(sessions)
+	moderationFeed := moderation.NewFeed(cfg.ModerationWebhook, serverState)
+	handlers.SetModerationFeed(moderationFeed)
+
+	// Endpoints were validated with the configuration
+	var webhooks *webhook.Dispatcher
+	if endpoints, _ := webhook.ParseEndpoints(cfg.Webhooks); len(endpoints) > 0 {
+		webhooks = webhook.NewDispatcher(serverState, sessions, webhook.Config{
+			Endpoints: endpoints,
+			Secret:    cfg.WebhookSecret,
+		})
+		handlers.SetWebhookDispatcher(webhooks)
+	}
 
 	// Create cancellable context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// Start health checking and metrics monitoring
-	utils.LogSDK("Starting health checking")
-	go monitoring.DoHealth(ctx, s, serverState, cancel)
-	go monitoring.MonitorMetrics(ctx, s, serverState)
-	go monitoring.MonitorSystemResources(ctx, serverState)
+	// Reassemble the raw output chunks into lines before parsing and dispatching them
+	serverReady := make(chan struct{}, 1)
+	lines := parser.NewLineWriter(func(line string) {
+		handlers.HandleServerOutput(format.Parse(line), s, serverState, serverReady, cancel)
+	})
 
-	// Setup initial GameServer configuration
-	if err := setupGameServer(s, serverState); err != nil {
-		utils.LogError("Failed to setup GameServer: %v", err)
+	// Prepare the Assetto Corsa server supervision
+	policy, _ := supervisor.ParsePolicy(cfg.RestartPolicy)
+	sup := supervisor.New(supervisor.Config{
+		Policy:      policy,
+		MaxRestarts: cfg.MaxRestarts,
+		Backoff:     cfg.RestartBackoff,
+		MaxBackoff:  cfg.RestartMaxBackoff,
+		ResetAfter:  restartResetAfter,
+	}, serverState, func(ctx context.Context) *exec.Cmd {
+		// Admin commands are sent to every new process
+		if commands != nil {
+			commands.Attach()
+		}
+		return prepareServerCommand(ctx, cfg, lines)
+	})
+	sup.OnExit = func(supervisor.ExitStatus) {
+		if commands != nil {
+			commands.Detach()
+		}
+		lines.Flush()
+		// Every player was disconnected along with the process
+		handlers.DisconnectProcessPlayers(s, serverState)
 	}
 
-	// Prepare and start the Assetto Corsa server
-	serverReady := make(chan struct{}, 1)
-	cmd := prepareServerCommand(ctx, input, args, s, serverState, serverReady)
-	if err := cmd.Start(); err != nil {
-		utils.LogError("Error Starting Cmd: %v", err)
+	liveness := monitoring.NewLiveness(monitoring.LivenessConfig{
+		OutputWindow:      cfg.LivenessOutputWindow,
+		RequireUpdateLoop: cfg.LivenessUpdateLoop,
+		StartupGrace:      cfg.LivenessStartupGrace,
+		InfoURL:           cfg.LivenessInfoURL,
+		HTTPTimeout:       time.Second,
+	}, serverState)
+
+	// Mirror the connected players into Agones player tracking, counters and lists
+	var tracker *players.Tracker
+	if cfg.PlayerTracking {
+		tracker = players.NewTracker(s.Alpha(), cfg.EntryList)
+		handlers.SetPlayerTracker(tracker)
+	}
+	var counters *players.Counters
+	if cfg.AgonesCounters {
+		counters = players.NewCounters(s.Alpha(), cfg.EntryList)
+		handlers.SetPlayerCounters(counters)
+	}
+
+	// Draining closes the server to new players and stops advertising its free slots. The
+	// configuration validation requires RCON whenever the server may be drained.
+	drainConfig := drain.Config{
+		Lock: func() error {
+			counters.Update(serverState)
+			return s.SetAnnotation("draining", "true")
+		},
+	}
+	if commands != nil {
+		drainConfig.Lock = func() error {
+			counters.Update(serverState)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			return errors.Join(commands.Lock(ctx), s.SetAnnotation("draining", "true"))
+		}
+		drainConfig.Broadcast = func(message string) error {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			return commands.Broadcast(ctx, message)
+		}
+	}
+	drainer := drain.NewDrainer(serverState, sessions, drainConfig)
+
+	shutdown := func(reason string) {
+		utils.LogSDK("Shutting down: %s", reason)
+		shutdownServer(cancel, s, serverState)
+	}
+
+	// Drains requested by operators or scheduled shut the server down once done
+	var drainServer func(reason string) error
+	if cfg.DrainTimeout > 0 {
+		drainServer = func(reason string) error {
+			return drainer.Start(reason, cfg.DrainTimeout, func(outcome string) {
+				shutdown("drained (" + outcome + ")")
+			})
+		}
+	}
+
+	schedulerConfig := scheduler.Config{
+		Restart: func() error {
+			sup.Restart()
+			return nil
+		},
+		Drain: drainServer,
+	}
+	if commands != nil {
+		schedulerConfig.Broadcast = commands.Broadcast
+	}
+	jobScheduler := scheduler.New(serverState, sessions, schedulerConfig)
+	configJobs, _ := scheduler.Parse(cfg.Schedule)
+	jobScheduler.SetJobs(scheduler.SourceConfig, configJobs)
+
+	// Follow the GameServer state and apply the allocation actions
+	// A configuration rendered on allocation applies once no player races on the old one
+	configRestart := &sessionRestart{sessions: sessions, state: serverState, restart: sup.Restart}
+	allocationActions, _ := allocation.ParseActions(cfg.AllocationActions)
+	watcher := allocation.NewWatcher(s, serverState, allocation.Config{
+		Actions:      allocationActions,
+		SessionLimit: cfg.AllocationLimit,
+		MetadataFile: cfg.AllocationMetadata,
+		Configure: func(labels, annotations map[string]string) error {
+			data := serverconfig.Data{Labels: labels, Annotations: annotations}
+			changed, err := serverconfig.Render(cfg.ConfigTemplates, cfg.ConfigRenderDir, data)
+			if err != nil {
+				return err
+			}
+			if !changed {
+				utils.LogSDK("Server configuration unchanged by the allocation")
+				return nil
+			}
+			utils.LogSDK("Server configuration rendered to %s", cfg.ConfigRenderDir)
+			configRestart.Request(ctx)
+			return nil
+		},
+		Allocated: func(_, annotations map[string]string) {
+			// Jobs of a previous allocation do not outlive it
+			jobs, err := scheduler.Parse(annotations[scheduler.Annotation])
+			if err != nil {
+				utils.LogError("Invalid %s annotation: %v", scheduler.Annotation, err)
+			}
+			jobScheduler.SetJobs(scheduler.SourceAnnotation, jobs)
+		},
+	}, shutdown)
+
+	// Observability endpoints come first so that they serve during the whole server life,
+	// followed by the webhooks so that the shutdown event is delivered, the game process
+	// comes last so that it is stopped first
+	orchestrator := lifecycle.NewOrchestrator()
+	orchestrator.Add(
+		lifecycle.HTTPServer("metrics server", newMetricsServer(cfg.MetricsPort)),
+		lifecycle.HTTPServer("health server", newHealthServer(cfg, serverState)),
+	)
+	if cfg.AdminPort != 0 {
+		adminConfig := admin.Config{
+			Token:     cfg.AdminToken,
+			Config:    cfg,
+			State:     serverState,
+			Sessions:  sessions,
+			Scheduler: jobScheduler,
+			Shutdown:  shutdown,
+			Drain:     drainServer,
+		}
+		if commands != nil {
+			adminConfig.Console = commands
+		}
+		orchestrator.Add(lifecycle.HTTPServer("admin server", newAdminServer(cfg.AdminPort, adminConfig)))
+	}
+	if webhooks != nil {
+		orchestrator.Add(lifecycle.Component{
+			Name:  "webhook dispatcher",
+			Start: webhooks.Start,
+			Stop:  webhooks.Stop,
+		})
+	}
+	orchestrator.Add(
+		lifecycle.Component{
+			Name: "GameServer setup",
+			Start: func(context.Context) error {
+				if err := setupGameServer(s, serverState); err != nil {
+					utils.LogError("Failed to setup GameServer: %v", err)
+				}
+				return nil
+			},
+		},
+		lifecycle.Component{
+			Name: "server configuration",
+			Start: func(context.Context) error {
+				// The server starts with the configuration of the GameServer metadata, so that
+				// the allocation only restarts it when its metadata changes the configuration
+				if cfg.ConfigTemplates != "" {
+					renderServerConfig(s, cfg)
+				}
+				return nil
+			},
+		},
+		lifecycle.Component{
+			Name: "allocation watcher",
+			Start: func(context.Context) error {
+				return watcher.Start()
+			},
+			Stop: func(context.Context) error {
+				watcher.Stop()
+				return nil
+			},
+		},
+		lifecycle.Go("health checking", func(ctx context.Context) {
+			monitoring.DoHealth(ctx, s, serverState, cancel, liveness, cfg.HealthCheckRate)
+		}),
+		lifecycle.Go("metrics monitoring", func(ctx context.Context) {
+			monitoring.MonitorMetrics(ctx, s, serverState, cfg.MetricsInterval, tracker, counters)
+		}),
+		lifecycle.Go("session monitoring", func(ctx context.Context) {
+			monitoring.MonitorSessions(ctx, sessions, serverState)
+		}),
+		lifecycle.Go("system resources monitoring", func(ctx context.Context) {
+			monitoring.MonitorSystemResources(ctx, serverState)
+		}),
+		lifecycle.Go("scheduler", jobScheduler.Run),
+	)
+	if counters != nil {
+		orchestrator.Add(lifecycle.Go("Agones counters", counters.Run))
+	}
+	if cfg.ResultsDir != "" || cfg.ResultsWebhook != "" {
+		exporter := results.NewExporter(cfg.ResultsDir, cfg.ResultsWebhook, serverState)
+		orchestrator.Add(lifecycle.Go("results export", func(ctx context.Context) {
+			exporter.Run(ctx, sessions)
+		}))
+	}
+	if cfg.ModerationWebhook != "" {
+		orchestrator.Add(lifecycle.Go("moderation webhook", moderationFeed.Run))
+	}
+	if cfg.ServerAPIURL != "" {
+		client := acapi.NewClient(cfg.ServerAPIURL, 5*time.Second)
+		orchestrator.Add(lifecycle.Go("server API polling", func(ctx context.Context) {
+			monitoring.PollServerAPI(ctx, client, serverState, sessions, cfg.ServerAPIInterval, func(steamID string) {
+				handlers.DisconnectPlayer(s, serverState, steamID, "missing from server API")
+			})
+		}))
+	}
+	idleAction, _ := monitoring.ParseIdleAction(cfg.IdleAction)
+	orchestrator.Add(lifecycle.Go("idle monitoring", func(ctx context.Context) {
+		monitoring.MonitorIdle(ctx, s, serverState, monitoring.IdleConfig{
+			Action:             idleAction,
+			NeverJoinedTimeout: cfg.IdleNeverJoined,
+			EmptyTimeout:       cfg.IdleEmpty,
+			Reset: func() error {
+				// Restarting the server process starts a fresh session
+				sup.Restart()
+				return nil
+			},
+			Shutdown: func() {
+				shutdownServer(cancel, s, serverState)
+			},
+			ServerReady: serverReady,
+		})
+	}))
+	orchestrator.Add(lifecycle.Go("game server process", func(ctx context.Context) {
+		superviseServer(ctx, cancel, sup, s, serverState)
+	}))
+
+	utils.LogEvent("SERVER_START", "Starting Assetto Corsa Server...")
+	if err := orchestrator.Start(ctx); err != nil {
+		utils.LogError("%v", err)
+		return
 	}
 
 	// Handle termination signals
-	setupSignalHandler(cancel, s, serverState, *shutdownTimeout)
+	setupSignalHandler(cancel, s.Shutdown, serverState, drainer, cfg)
 
 	// Wait for server readiness and manage lifecycle
-	waitForServerEnd(ctx, serverReady, s, *reserveDuration)
+	waitForServerEnd(ctx, serverReady, s.Ready, webhooks)
 
-	// Initialize Prometheus metrics
-	initMetrics()
+	// Stop the components in reverse order
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), componentStopTimeout)
+	defer stopCancel()
+	orchestrator.Stop(stopCtx)
+}
+
+// newMetricsServer creates the HTTP server exposing the Prometheus metrics.
+func newMetricsServer(port int) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
 
-	// Utiliser logEvent pour les messages importants
-	logEvent("SERVER_START", "Starting Assetto Corsa Server...", serverState)
+	return &http.Server{
+		Addr:    fmt.Sprintf(":%d", port),
+		Handler: mux,
+	}
+}
 
-	// Create a separate mux for health checks
+// newHealthServer creates the HTTP server exposing the health and configuration endpoints.
+func newHealthServer(cfg *types.Config, serverState *types.ServerState) *http.Server {
 	healthMux := http.NewServeMux()
 
 	// Add HTTP health endpoint
@@ -113,7 +438,7 @@ func main() {
 			msg   string
 		}{
 			{serverState.Ready, "Server not ready"},
-			{time.Since(serverState.LastPing) < 5*time.Second, "Health check timeout"},
+			{time.Since(serverState.LastPing) < 2*cfg.HealthCheckRate+time.Second, "Health check timeout"},
 			{!serverState.ShuttingDown, "Server is shutting down"},
 		}
 
@@ -130,60 +455,175 @@ func main() {
 		w.Write([]byte("OK"))
 	})
 
-	// Start HTTP server for health checks on a separate port
-	go func() {
-		server := &http.Server{
-			Addr:         ":9001",
-			Handler:      healthMux,
-			ReadTimeout:  5 * time.Second,
-			WriteTimeout: 5 * time.Second,
+	// Expose the effective configuration
+	healthMux.HandleFunc("/config", func(w http.ResponseWriter, r *http.Request) {
+		data, err := config.JSON(cfg)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
 		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	})
 
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			utils.LogError("HTTP health server error: %v", err)
-		}
-	}()
+	return &http.Server{
+		Addr:         fmt.Sprintf(":%d", cfg.HealthPort),
+		Handler:      healthMux,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 5 * time.Second,
+	}
+}
+
+// newAdminServer creates the HTTP server exposing the admin API on the admin port.
+func newAdminServer(port int, config admin.Config) *http.Server {
+	return &http.Server{
+		Addr:         fmt.Sprintf(":%d", port),
+		Handler:      admin.New(config),
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
 }
 
 // prepareServerCommand creates and configures the exec.Cmd for the Assetto Corsa server.
 // It sets up output interception and command arguments.
-func prepareServerCommand(ctx context.Context, input *string, args *string, s *sdk.SDK, state *types.ServerState, serverReady chan struct{}) *exec.Cmd {
-	argsList := strings.Fields(*args)
-	cmd := exec.CommandContext(ctx, *input, argsList...)
+// The directory of the rendered configuration is passed to the script in AC_RENDERED_CONFIG.
+func prepareServerCommand(ctx context.Context, cfg *types.Config, output io.Writer) *exec.Cmd {
+	argsList := strings.Fields(cfg.ServerArgs)
+	cmd := exec.CommandContext(ctx, cfg.ServerScript, argsList...)
+	if cfg.ConfigTemplates != "" {
+		cmd.Env = append(os.Environ(), "AC_RENDERED_CONFIG="+cfg.ConfigRenderDir)
+	}
 	cmd.Stderr = &interceptor{forward: os.Stderr}
 
 	cmd.Stdout = &interceptor{
 		forward: os.Stdout,
 		intercept: func(p []byte) {
-			str := strings.TrimSpace(string(p))
-			handlers.HandleServerOutput(str, s, state, serverReady, nil)
+			output.Write(p)
 		},
 	}
 
 	return cmd
 }
 
-// waitForServerEnd waits for the server to signal readiness.
-// It returns an error if the server fails to become ready within the timeout period.
-func waitForServerEnd(ctx context.Context, serverReady chan struct{}, s *sdk.SDK, reserveDuration time.Duration) {
+// superviseServer runs the server process supervisor.
+// When the supervisor gives up, the GameServer is shut down.
+func superviseServer(ctx context.Context, cancel context.CancelFunc, sup *supervisor.Supervisor, s *sdk.SDK, state *types.ServerState) {
+	if err := sup.Run(ctx); err != nil {
+		utils.LogError("%v", err)
+		shutdownServer(cancel, s, state)
+	}
+}
+
+// emptyCheckInterval is the interval at which a pending session restart checks whether the
+// server is empty.
+var emptyCheckInterval = 5 * time.Second
+
+// sessionRestart restarts the server once no player takes part in a session, as the scheduled
+// restarts do: at once when the server is empty, otherwise at the end of the current session or
+// when the last player leaves. Requests made while a restart is pending are merged into it.
+type sessionRestart struct {
+	sessions *session.SessionManager
+	state    *types.ServerState
+	restart  func()
+	pending  atomic.Bool
+}
+
+// Request restarts the server, or waits in the background for the end of the current session.
+// A pending restart is abandoned when ctx is cancelled.
+func (r *sessionRestart) Request(ctx context.Context) {
+	if !r.pending.CompareAndSwap(false, true) {
+		utils.LogSDK("Server restart already pending until the end of the session")
+		return
+	}
+	// Subscribing first so that no session end is missed
+	transitions, unsubscribe := r.sessions.Subscribe(4)
+	if r.empty() {
+		unsubscribe()
+		r.pending.Store(false)
+		utils.LogSDK("Restarting the server")
+		r.restart()
+		return
+	}
+
+	utils.LogSDK("Players are connected, restarting the server at the end of the session")
+	go func() {
+		defer unsubscribe()
+		ticker := time.NewTicker(emptyCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case transition, ok := <-transitions:
+				if !ok {
+					return
+				}
+				if transition.Ended == nil {
+					continue
+				}
+			case <-ticker.C:
+				if !r.empty() {
+					continue
+				}
+			}
+			// A request made from now on needs a new restart
+			r.pending.Store(false)
+			utils.LogSDK("Session over, restarting the server")
+			r.restart()
+			return
+		}
+	}()
+}
+
+// empty returns whether no player takes part in a session.
+func (r *sessionRestart) empty() bool {
+	if r.sessions.GetCurrentSession() == nil {
+		return true
+	}
+	r.state.RLock()
+	defer r.state.RUnlock()
+	return r.state.Players == 0
+}
+
+// shutdownServer marks the server as shutting down, notifies Agones and cancels the context.
+func shutdownServer(cancel context.CancelFunc, s *sdk.SDK, state *types.ServerState) {
+	state.Lock()
+	state.ShuttingDown = true
+	state.Unlock()
+
+	if err := s.Shutdown(); err != nil {
+		utils.LogError("Failed to notify Agones of shutdown: %v", err)
+	}
+	cancel()
+}
+
+// waitForServerEnd waits for the server to signal readiness, marks the GameServer as Ready
+// with ready, then waits for the shutdown. Both are published to the webhooks.
+func waitForServerEnd(ctx context.Context, serverReady chan struct{}, ready func() error, webhooks *webhook.Dispatcher) {
 	select {
 	case <-serverReady:
 		utils.LogSDK("Server reported ready, marking GameServer as Ready")
-		if err := s.Ready(); err != nil {
+		if err := ready(); err != nil {
 			utils.LogError("Error marking server as ready: %v", err)
+		} else {
+			webhooks.Publish(webhook.Event{LogEvent: types.LogEvent{Event: webhook.EventReady, Message: "GameServer marked as Ready"}})
 		}
 	case <-ctx.Done():
 		utils.LogSDK("Context cancelled, initiating graceful shutdown")
+		webhooks.Publish(webhook.Event{LogEvent: types.LogEvent{Event: webhook.EventShutdown, Message: "Server shutting down before being ready"}})
 		return
 	}
 
 	// Add graceful shutdown handling
 	<-ctx.Done()
 	utils.LogSDK("Server shutdown initiated")
+	webhooks.Publish(webhook.Event{LogEvent: types.LogEvent{Event: webhook.EventShutdown, Message: "Server shutting down"}})
 }
 
 // setupSignalHandler configures signal handling for graceful shutdown.
-func setupSignalHandler(cancel context.CancelFunc, s *sdk.SDK, state *types.ServerState, timeout time.Duration) {
+// The server is drained first, within the termination grace period; a second signal ends the drain.
+// Agones is then notified of the shutdown with notify.
+func setupSignalHandler(cancel context.CancelFunc, notify func() error, state *types.ServerState, drainer *drain.Drainer, cfg *types.Config) {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGTERM, syscall.SIGINT)
 
@@ -191,44 +631,60 @@ func setupSignalHandler(cancel context.CancelFunc, s *sdk.SDK, state *types.Serv
 		sig := <-sigChan
 		utils.LogSDK("Received signal %v, initiating shutdown", sig)
 
+		// Leave enough of the grace period for the shutdown itself
+		timeout := cfg.DrainTimeout
+		if limit := cfg.TerminationGrace - cfg.ShutdownTimeout; limit < timeout {
+			timeout = limit
+		}
+		if timeout > 0 {
+			drained := make(chan string, 1)
+			err := drainer.Start(fmt.Sprintf("received %v", sig), timeout, func(outcome string) {
+				drained <- outcome
+			})
+			if err != nil {
+				// A drain requested earlier may outlast the grace period
+				drainer.Skip()
+			} else {
+				select {
+				case <-drained:
+				case sig := <-sigChan:
+					utils.LogSDK("Received signal %v, ending the drain", sig)
+					drainer.Skip()
+					<-drained
+				}
+			}
+		}
+
 		state.Lock()
 		state.ShuttingDown = true
 		state.Unlock()
 
 		// Notify Agones of shutdown
-		if err := s.Shutdown(); err != nil {
+		if err := notify(); err != nil {
 			utils.LogError("Failed to notify Agones of shutdown: %v", err)
 		}
 
-		time.Sleep(timeout)
+		time.Sleep(cfg.ShutdownTimeout)
 		cancel()
 	}()
 }
 
-// initMetrics initializes and exposes Prometheus metrics
-func initMetrics() {
-	// Expose metrics on /metrics
-	http.Handle("/metrics", promhttp.Handler())
-	go func() {
-		if err := http.ListenAndServe(":9090", nil); err != nil {
-			utils.LogWarning("Metrics server failed: %v", err)
-		}
-	}()
-}
-
-// logEvent logs important events
-func logEvent(eventType string, message string, state *types.ServerState) {
-	sessionType := "unknown"
-	if state.CurrentSession != nil {
-		sessionType = state.CurrentSession.Type
+// renderServerConfig renders the server configuration templates with the metadata of the GameServer.
+// On failure, the server starts with the previous or the shared configuration.
+func renderServerConfig(s *sdk.SDK, cfg *types.Config) {
+	gameServer, err := s.GameServer()
+	if err != nil {
+		utils.LogError("Failed to get GameServer, server configuration not rendered: %v", err)
+		return
 	}
 
-	log.Printf("[%s] %s | Server: %s | Players: %d | Session: %s",
-		eventType,
-		message,
-		state.ServerName,
-		state.Players,
-		sessionType)
+	meta := gameServer.GetObjectMeta()
+	data := serverconfig.Data{Labels: meta.GetLabels(), Annotations: meta.GetAnnotations()}
+	if _, err := serverconfig.Render(cfg.ConfigTemplates, cfg.ConfigRenderDir, data); err != nil {
+		utils.LogError("Failed to render the server configuration: %v", err)
+		return
+	}
+	utils.LogSDK("Server configuration rendered to %s", cfg.ConfigRenderDir)
 }
 
 // setupGameServer initializes the GameServer configuration
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"agones/drain"
+	"agones/handlers"
+	"agones/lifecycle"
+	"agones/parser"
+	"agones/session"
+	"agones/supervisor"
+	"agones/types"
+)
+
+// stubServer stands in for AssettoServer: it logs its startup, leaves a child process
+// running as the real server does, and exits cleanly on SIGTERM.
+const stubServer = `#!/bin/sh
+trap 'echo "[12:00:09 INF] Server shutting down"; exit 0' TERM
+echo "[12:00:00 INF] Starting update loop with an update rate of 18hz"
+echo "[12:00:00 INF] Lobby registration successful"
+sleep 300 &
+echo $! > "$1"
+wait
+`
+
+// events records the lifecycle steps in the order they happen.
+type events struct {
+	mu   sync.Mutex
+	list []string
+}
+
+func (e *events) add(event string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.list = append(e.list, event)
+}
+
+func (e *events) get() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string(nil), e.list...)
+}
+
+func TestServerLifecycle(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "start-server.sh")
+	if err := os.WriteFile(script, []byte(stubServer), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	pidFile := filepath.Join(dir, "child.pid")
+
+	cfg := &types.Config{
+		ServerScript:     script,
+		ServerArgs:       pidFile,
+		DrainTimeout:     5 * time.Second,
+		TerminationGrace: 10 * time.Second,
+		ShutdownTimeout:  10 * time.Millisecond,
+		HealthCheckRate:  time.Second,
+		MetricsPort:      freePort(t),
+		HealthPort:       freePort(t),
+	}
+	health := fmt.Sprintf("http://127.0.0.1:%d/health", cfg.HealthPort)
+	metrics := fmt.Sprintf("http://127.0.0.1:%d/metrics", cfg.MetricsPort)
+	state := &types.ServerState{
+		ConnectedPlayers: make(map[string]*types.Player),
+		ActiveCars:       make(map[string]int),
+		CurrentSession:   &types.Session{Type: "initializing"},
+	}
+	var steps events
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	format, _ := parser.ParseFormat("text")
+	serverReady := make(chan struct{}, 1)
+	lines := parser.NewLineWriter(func(line string) {
+		handlers.HandleServerOutput(format.Parse(line), nil, state, serverReady, cancel)
+	})
+	sup := supervisor.New(supervisor.Config{Policy: supervisor.PolicyShutdown}, state, func(ctx context.Context) *exec.Cmd {
+		return prepareServerCommand(ctx, cfg, lines)
+	})
+	sup.OnExit = func(supervisor.ExitStatus) {
+		lines.Flush()
+		steps.add("exit")
+	}
+	drainer := drain.NewDrainer(state, session.NewSessionManager(10), drain.Config{
+		Lock: func() error {
+			steps.add("drain")
+			return nil
+		},
+	})
+
+	orchestrator := lifecycle.NewOrchestrator()
+	orchestrator.Add(
+		lifecycle.HTTPServer("metrics server", newMetricsServer(cfg.MetricsPort)),
+		lifecycle.HTTPServer("health server", newHealthServer(cfg, state)),
+		lifecycle.Component{
+			Name: "observer",
+			Start: func(context.Context) error {
+				steps.add("start")
+				// The observability endpoints answer before the server is Ready
+				if code := get(t, health); code != http.StatusServiceUnavailable {
+					t.Errorf("/health before Ready = %d, want %d", code, http.StatusServiceUnavailable)
+				}
+				if code := get(t, metrics); code != http.StatusOK {
+					t.Errorf("/metrics before Ready = %d, want %d", code, http.StatusOK)
+				}
+				return nil
+			},
+			Stop: func(context.Context) error {
+				steps.add("stop")
+				return nil
+			},
+		},
+		lifecycle.Go("game server process", func(ctx context.Context) {
+			if err := sup.Run(ctx); err != nil {
+				t.Errorf("supervisor: %v", err)
+			}
+		}),
+	)
+	if err := orchestrator.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	setupSignalHandler(cancel, func() error {
+		steps.add("shutdown")
+		return nil
+	}, state, drainer, cfg)
+
+	ready := make(chan struct{})
+	ended := make(chan struct{})
+	go func() {
+		defer close(ended)
+		waitForServerEnd(ctx, serverReady, func() error {
+			steps.add("ready")
+			close(ready)
+			return nil
+		}, nil)
+	}()
+
+	select {
+	case <-ready:
+	case <-time.After(10 * time.Second):
+		t.Fatal("server never reported ready")
+	}
+	// The health pings are not sent in this test
+	state.Lock()
+	state.LastPing = time.Now()
+	state.Unlock()
+	if code := get(t, health); code != http.StatusOK {
+		t.Errorf("/health of the running server = %d, want %d", code, http.StatusOK)
+	}
+	if code := get(t, metrics); code != http.StatusOK {
+		t.Errorf("/metrics of the running server = %d, want %d", code, http.StatusOK)
+	}
+	steps.add("sigterm")
+	if err := syscall.Kill(os.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-ended:
+	case <-time.After(10 * time.Second):
+		t.Fatal("server never shut down")
+	}
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), componentStopTimeout)
+	defer stopCancel()
+	orchestrator.Stop(stopCtx)
+
+	want := []string{"start", "ready", "sigterm", "drain", "shutdown", "exit", "stop"}
+	if got := steps.get(); !reflect.DeepEqual(got, want) {
+		t.Errorf("steps = %q, want %q", got, want)
+	}
+	if !state.Ready || !state.ShuttingDown {
+		t.Errorf("Ready = %v, ShuttingDown = %v", state.Ready, state.ShuttingDown)
+	}
+
+	pid, err := os.ReadFile(pidFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "child process "+strings.TrimSpace(string(pid))+" to exit", func() bool {
+		return !processAlive(strings.TrimSpace(string(pid)))
+	})
+	waitFor(t, "the wrapper goroutines to exit", func() bool {
+		return len(wrapperGoroutines()) == 0
+	})
+	for _, stack := range wrapperGoroutines() {
+		t.Logf("leaked goroutine:\n%s", stack)
+	}
+}
+
+func TestSessionRestart(t *testing.T) {
+	defer func(interval time.Duration) { emptyCheckInterval = interval }(emptyCheckInterval)
+	emptyCheckInterval = 10 * time.Millisecond
+
+	var mu sync.Mutex
+	restarts := 0
+	restarted := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return restarts
+	}
+	state := &types.ServerState{}
+	sessions := session.NewSessionManager(10)
+	r := &sessionRestart{sessions: sessions, state: state, restart: func() {
+		mu.Lock()
+		restarts++
+		mu.Unlock()
+	}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// No session, the restart is immediate
+	r.Request(ctx)
+	if n := restarted(); n != 1 {
+		t.Fatalf("%d restarts without a session, want 1", n)
+	}
+
+	// Players racing, the restart waits for the end of the session
+	sessions.StartNewSession(types.SessionTypeRace, "monza")
+	state.Lock()
+	state.Players = 2
+	state.Unlock()
+	r.Request(ctx)
+	r.Request(ctx)
+	time.Sleep(50 * time.Millisecond)
+	if n := restarted(); n != 1 {
+		t.Fatalf("%d restarts during the session, want 1", n)
+	}
+	sessions.EndCurrentSession()
+	waitFor(t, "the restart at the end of the session", func() bool { return restarted() == 2 })
+
+	// The restart happens when the last player leaves
+	sessions.StartNewSession(types.SessionTypePractice, "monza")
+	r.Request(ctx)
+	time.Sleep(50 * time.Millisecond)
+	if n := restarted(); n != 2 {
+		t.Fatalf("%d restarts during the session, want 2", n)
+	}
+	state.Lock()
+	state.Players = 0
+	state.Unlock()
+	waitFor(t, "the restart of the empty server", func() bool { return restarted() == 3 })
+
+	time.Sleep(50 * time.Millisecond)
+	if n := restarted(); n != 3 {
+		t.Errorf("%d restarts, want merged requests to restart once", n)
+	}
+}
+
+// freePort returns a TCP port available for listening.
+func freePort(t *testing.T) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// get requests url and returns the response status, 0 if the request failed.
+func get(t *testing.T, url string) int {
+	t.Helper()
+	client := http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Errorf("GET %s: %v", url, err)
+		return 0
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode
+}
+
+// waitFor fails the test if cond does not hold within a few seconds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Errorf("timed out waiting for %s", what)
+			return
+		}
+	}
+}
+
+// processAlive reports whether the process exists and is not a zombie left to a non-reaping init.
+func processAlive(pid string) bool {
+	stat, err := os.ReadFile("/proc/" + pid + "/stat")
+	if err != nil {
+		return false
+	}
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return len(fields) > 0 && fields[0] != "Z"
+}
+
+// wrapperGoroutines returns the stacks of the goroutines, other than the caller, running
+// code of the wrapper or copying a process output.
+func wrapperGoroutines() []string {
+	buf := make([]byte, 1<<20)
+	stacks := strings.Split(string(buf[:runtime.Stack(buf, true)]), "\n\n")
+
+	var leaked []string
+	for _, stack := range stacks[1:] {
+		if strings.Contains(stack, "_testmain.go") {
+			continue
+		}
+		if strings.Contains(stack, "\nmain.") || strings.Contains(stack, "agones/") || strings.Contains(stack, "os/exec.") {
+			leaked = append(leaked, stack)
+		}
+	}
+	return leaked
+}
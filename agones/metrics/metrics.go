@@ -44,6 +44,30 @@ var (
 		Help: "Time since last successful health ping in seconds",
 	}, ServerLabels)
 
+	// LivenessProbeFailuresCounter tracks failed liveness probes withholding health pings
+	LivenessProbeFailuresCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "assetto_server_liveness_probe_failures_total",
+		Help: "Total number of failed liveness probes by probe",
+	}, append(ServerLabels, "probe"))
+
+	// IdleActionsCounter tracks the idle policy actions applied to allocated servers without players
+	IdleActionsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "assetto_server_idle_actions_total",
+		Help: "Total number of idle policy actions by reason, action and outcome",
+	}, append(ServerLabels, "reason", "action", "outcome"))
+
+	// ScheduledJobsCounter tracks the executions of the scheduled jobs
+	ScheduledJobsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "assetto_server_scheduled_jobs_total",
+		Help: "Total number of scheduled job executions by action and outcome (success, failure, deferred)",
+	}, append(ServerLabels, "action", "outcome"))
+
+	// DrainsCounter tracks the drains preceding a shutdown
+	DrainsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "assetto_server_drains_total",
+		Help: "Total number of drains by outcome",
+	}, append(ServerLabels, "outcome"))
+
 	// TickRateGauge tracks the current server tick rate
 	TickRateGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "assetto_server_tick_rate",
@@ -159,6 +183,22 @@ var (
 		Help: "Player best lap time in milliseconds",
 	}, append(ServerLabels, "player_name", "steam_id"))
 
+	// ResultsExportsCounter tracks the exports of session results
+	ResultsExportsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "assetto_server_results_exports_total",
+		Help: "Total number of session results exports by target and outcome",
+	}, append(ServerLabels, "target", "outcome"))
+
+	// LapTimeHistogram tracks the distribution of lap times per track and car
+	LapTimeHistogram = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "assetto_server_lap_time_seconds",
+			Help:    "Distribution of completed lap times in seconds",
+			Buckets: prometheus.ExponentialBuckets(30, 1.25, 16), // From 30 seconds to about 14 minutes
+		},
+		append(ServerLabels, "track", "car_model"),
+	)
+
 	// PlayerDisconnectCounter tracks player disconnections
 	PlayerDisconnectCounter = promauto.NewCounterVec(prometheus.CounterOpts{
 		Name: "assetto_server_player_disconnects_total",
@@ -198,6 +238,24 @@ var (
 		Help: "Total number of server starts",
 	}, ServerLabels)
 
+	// ServerRestartCounter tracks restarts of the server process by the supervisor
+	ServerRestartCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "assetto_server_restarts_total",
+		Help: "Total number of server process restarts",
+	}, ServerLabels)
+
+	// ServerExitCounter tracks server process exits by exit code and signal
+	ServerExitCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "assetto_server_exits_total",
+		Help: "Total number of server process exits by exit code and signal",
+	}, append(ServerLabels, "exit_code", "signal"))
+
+	// ServerLastExitCodeGauge tracks the exit code of the last server process run
+	ServerLastExitCodeGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "assetto_server_last_exit_code",
+		Help: "Exit code of the last server process run (-1 if killed by a signal)",
+	}, ServerLabels)
+
 	// SessionEndCounter tracks session ends
 	SessionEndCounter = promauto.NewCounterVec(prometheus.CounterOpts{
 		Name: "assetto_server_ends_total",
@@ -217,6 +275,12 @@ var (
 		append(ServerLabels, "command_type"),
 	)
 
+	// CommandsCounter tracks the admin commands sent to the server
+	CommandsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "assetto_server_commands_total",
+		Help: "Total number of admin commands sent to the server by command and outcome",
+	}, append(ServerLabels, "command_type", "outcome"))
+
 	// PlayerLatencyHistogram tracks player latency distribution
 	PlayerLatencyHistogram = promauto.NewHistogramVec(
 		prometheus.HistogramOpts{
@@ -260,3 +324,72 @@ var (
 		Help: "Total number of chat messages",
 	}, ServerLabels)
 )
+
+// Moderation metrics
+var (
+	// ModerationEventsCounter tracks moderation events by type
+	ModerationEventsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "assetto_server_moderation_events_total",
+		Help: "Total number of moderation events (kicks, bans, refused connections) by type",
+	}, append(ServerLabels, "type"))
+)
+
+// Webhook metrics
+var (
+	// WebhookDeliveriesCounter tracks webhook deliveries by event type and outcome
+	WebhookDeliveriesCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "assetto_server_webhook_deliveries_total",
+		Help: "Total number of webhook event deliveries by event type and outcome (success, failure, dropped, circuit_open)",
+	}, append(ServerLabels, "event", "outcome"))
+)
+
+// Server API reconciliation metrics
+var (
+	// StateDriftGauge tracks the difference between output-derived and API-derived state
+	StateDriftGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "assetto_server_state_drift",
+		Help: "Difference between the state derived from server output and from the server API, by field",
+	}, append(ServerLabels, "field"))
+
+	// StateCorrectionsCounter tracks corrections of the state applied from the server API
+	StateCorrectionsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "assetto_server_state_corrections_total",
+		Help: "Total number of server state corrections applied from the server API, by field",
+	}, append(ServerLabels, "field"))
+)
+
+// Output processing metrics
+var (
+	// ServerOutputLinesCounter tracks lines of server output by log level
+	ServerOutputLinesCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "assetto_server_output_lines_total",
+		Help: "Total number of server output lines by log level",
+	}, append(ServerLabels, "level"))
+
+	// RuleMatchesCounter tracks how many times each output rule matched
+	RuleMatchesCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "assetto_server_rule_matches_total",
+		Help: "Total number of server output lines matched by each rule",
+	}, append(ServerLabels, "rule"))
+)
+
+// namedCounters lists the counters carrying only ServerLabels that output rules
+// can reference by name with the increment_metric action.
+var namedCounters = map[string]*prometheus.CounterVec{
+	"assetto_server_player_connects_total":      PlayerConnectCounter,
+	"assetto_server_player_disconnects_total":   PlayerDisconnectCounter,
+	"assetto_server_auth_success_total":         AuthSuccessCounter,
+	"assetto_server_session_changes_total":      SessionChangeCounter,
+	"assetto_server_lobby_registrations_total":  LobbyRegistrationCounter,
+	"assetto_server_starts_total":               ServerStartCounter,
+	"assetto_server_restarts_total":             ServerRestartCounter,
+	"assetto_server_ends_total":                 SessionEndCounter,
+	"assetto_server_chat_messages_total":        ChatMessagesCounter,
+	"assetto_server_health_ping_failures_total": HealthPingFailuresCounter,
+}
+
+// LookupCounter returns the counter registered under the given metric name.
+func LookupCounter(name string) (*prometheus.CounterVec, bool) {
+	counter, ok := namedCounters[name]
+	return counter, ok
+}
@@ -0,0 +1,124 @@
+// Package moderation records the moderation events of the server, such as kicks, bans and
+// refused connections, to the metrics, the structured log and optionally a webhook.
+package moderation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"agones/metrics"
+	"agones/types"
+	"agones/utils"
+)
+
+// queueSize is the number of events waiting for the webhook before new ones are dropped.
+const queueSize = 64
+
+// Feed records moderation events.
+type Feed struct {
+	state      *types.ServerState
+	webhookURL string // URL the events are posted to, empty to skip the webhook
+	client     *http.Client
+	queue      chan types.ModerationEvent
+}
+
+// NewFeed creates a Feed posting the events to webhookURL, if not empty.
+func NewFeed(webhookURL string, state *types.ServerState) *Feed {
+	return &Feed{
+		state:      state,
+		webhookURL: webhookURL,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		queue:      make(chan types.ModerationEvent, queueSize),
+	}
+}
+
+// Record fills in the server context of event, then counts, logs and queues it for the webhook.
+// It never blocks: an event is dropped from the webhook if the queue is full.
+func (f *Feed) Record(event types.ModerationEvent) {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	if event.Actor == "" {
+		event.Actor = "server"
+	}
+
+	f.state.RLock()
+	event.ServerID = f.state.ServerID
+	event.ServerName = f.state.ServerName
+	labels := prometheus.Labels{
+		"server_id":   f.state.ServerID,
+		"server_name": f.state.ServerName,
+		"server_type": f.state.ServerType,
+		"type":        event.Type,
+	}
+	f.state.RUnlock()
+
+	metrics.ModerationEventsCounter.With(labels).Inc()
+	utils.LogEvent("MODERATION", "%s: %s (%s) by %s, reason: %s",
+		event.Type, event.Name, event.SteamID, event.Actor, event.Reason)
+
+	if f.webhookURL == "" {
+		return
+	}
+	select {
+	case f.queue <- event:
+	default:
+		utils.LogWarning("Moderation webhook queue full, %s event for %s dropped", event.Type, event.SteamID)
+	}
+}
+
+// Run posts the queued events to the webhook until ctx is cancelled, then posts the events
+// still queued so that the last events before shutting down are delivered.
+func (f *Feed) Run(ctx context.Context) {
+	for {
+		select {
+		case event := <-f.queue:
+			f.deliver(event)
+		case <-ctx.Done():
+			for {
+				select {
+				case event := <-f.queue:
+					f.deliver(event)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// deliver posts an event to the webhook, logging failures.
+func (f *Feed) deliver(event types.ModerationEvent) {
+	if err := f.post(event); err != nil {
+		utils.LogWarning("Failed to post %s moderation event: %v", event.Type, err)
+	}
+}
+
+// post posts an event to the webhook.
+func (f *Feed) post(event types.ModerationEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, f.webhookURL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package moderation
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"agones/types"
+)
+
+// receiver is a moderation webhook recording the events posted to it.
+type receiver struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	events []types.ModerationEvent
+}
+
+func newReceiver(t *testing.T) *receiver {
+	r := &receiver{}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		var event types.ModerationEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			t.Errorf("invalid event %s: %v", body, err)
+		}
+		r.mu.Lock()
+		r.events = append(r.events, event)
+		r.mu.Unlock()
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *receiver) received() []types.ModerationEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]types.ModerationEvent(nil), r.events...)
+}
+
+var testState = &types.ServerState{ServerID: "gs-1", ServerName: "Monza Practice", ServerType: "practice"}
+
+func TestRecord(t *testing.T) {
+	f := NewFeed("http://127.0.0.1/moderation", testState)
+	before := time.Now()
+	f.Record(types.ModerationEvent{Type: types.ModerationKick, SteamID: "76561198000000001", Name: "Bob", Reason: "spam"})
+	f.Record(types.ModerationEvent{Type: types.ModerationBan, Name: "Eve", Actor: "admin", Timestamp: before.Add(-time.Hour)})
+
+	if len(f.queue) != 2 {
+		t.Fatalf("%d events queued, want 2", len(f.queue))
+	}
+	kick, ban := <-f.queue, <-f.queue
+	if kick.Actor != "server" || kick.Timestamp.Before(before) {
+		t.Errorf("kick by %q at %v, want the server actor and the current time", kick.Actor, kick.Timestamp)
+	}
+	if kick.ServerID != "gs-1" || kick.ServerName != "Monza Practice" {
+		t.Errorf("kick on %q (%q), want the server context", kick.ServerID, kick.ServerName)
+	}
+	if ban.Actor != "admin" || !ban.Timestamp.Equal(before.Add(-time.Hour)) {
+		t.Errorf("ban by %q at %v, want the given actor and time", ban.Actor, ban.Timestamp)
+	}
+}
+
+func TestRecordWithoutWebhook(t *testing.T) {
+	f := NewFeed("", testState)
+	f.Record(types.ModerationEvent{Type: types.ModerationKick, Name: "Bob"})
+	if len(f.queue) != 0 {
+		t.Errorf("%d events queued without a webhook", len(f.queue))
+	}
+}
+
+func TestRecordQueueFull(t *testing.T) {
+	f := NewFeed("http://127.0.0.1/moderation", testState)
+
+	// Nothing is delivered before Run, so the queue fills up without blocking
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < queueSize+10; i++ {
+			f.Record(types.ModerationEvent{Type: types.ModerationAuthFailure, Name: "Bob"})
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Record blocked on a full queue")
+	}
+	if len(f.queue) != queueSize {
+		t.Errorf("%d events queued, want %d", len(f.queue), queueSize)
+	}
+}
+
+func TestRunFlushesOnCancel(t *testing.T) {
+	r := newReceiver(t)
+	f := NewFeed(r.URL, testState)
+	for _, name := range []string{"Bob", "Eve", "Carl"} {
+		f.Record(types.ModerationEvent{Type: types.ModerationBan, Name: name})
+	}
+
+	// The events queued before the shutdown are still delivered
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	f.Run(ctx)
+
+	received := r.received()
+	if len(received) != 3 {
+		t.Fatalf("%d events delivered, want 3", len(received))
+	}
+	if received[0].Name != "Bob" || received[0].Type != types.ModerationBan || received[0].ServerID != "gs-1" {
+		t.Errorf("delivered %+v", received[0])
+	}
+}
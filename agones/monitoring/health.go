@@ -13,22 +13,47 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 
 	"agones/metrics"
+	"agones/players"
 	"agones/types"
 	"agones/utils"
 )
 
 // DoHealth performs periodic health checks of the server.
-// It pings the Agones SDK and updates relevant metrics based on the health status.
-// If a health check fails, it initiates a graceful shutdown of the server.
-func DoHealth(ctx context.Context, s *sdk.SDK, state *types.ServerState, cancel context.CancelFunc) {
-	ticker := time.NewTicker(2 * time.Second)
+// It pings the Agones SDK only while the liveness probes pass, so that Agones marks
+// the GameServer Unhealthy when the game process is dead or hung, and updates
+// relevant metrics based on the health status.
+// If a health ping fails, it initiates a graceful shutdown of the server.
+func DoHealth(ctx context.Context, s *sdk.SDK, state *types.ServerState, cancel context.CancelFunc, liveness *Liveness, rate time.Duration) {
+	ticker := time.NewTicker(rate)
 	defer ticker.Stop()
 
+	var lastFailure string
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
+			// Withhold the ping while a liveness probe fails
+			if probeName, err := liveness.Check(ctx); err != nil {
+				if probeName != lastFailure {
+					utils.LogWarning("Liveness probe %s failed, withholding health pings: %v", probeName, err)
+					lastFailure = probeName
+				}
+				state.RLock()
+				metrics.LivenessProbeFailuresCounter.With(prometheus.Labels{
+					"server_id":   state.ServerID,
+					"server_name": state.ServerName,
+					"server_type": state.ServerType,
+					"probe":       probeName,
+				}).Inc()
+				state.RUnlock()
+				continue
+			}
+			if lastFailure != "" {
+				utils.LogSDK("Liveness probes passing again, resuming health pings")
+				lastFailure = ""
+			}
+
 			state.Lock()
 			state.LastPing = time.Now()
 			state.Unlock()
@@ -83,8 +108,9 @@ func DoHealth(ctx context.Context, s *sdk.SDK, state *types.ServerState, cancel
 
 // MonitorMetrics monitors and updates the server's metrics periodically.
 // It retrieves the GameServer status and updates annotations and detailed metrics.
-func MonitorMetrics(ctx context.Context, s *sdk.SDK, state *types.ServerState) {
-	ticker := time.NewTicker(30 * time.Second)
+// When enabled, the Agones player list, counters and lists are reconciled with the server state.
+func MonitorMetrics(ctx context.Context, s *sdk.SDK, state *types.ServerState, interval time.Duration, tracker *players.Tracker, counters *players.Counters) {
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
@@ -110,6 +136,9 @@ func MonitorMetrics(ctx context.Context, s *sdk.SDK, state *types.ServerState) {
 				updateDetailedMetrics(s, state)
 			}
 			state.RUnlock()
+
+			tracker.Reconcile(state)
+			counters.Update(state)
 		}
 	}
 }
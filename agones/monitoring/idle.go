@@ -0,0 +1,181 @@
+package monitoring
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	sdk "agones.dev/agones/sdks/go"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"agones/metrics"
+	"agones/types"
+	"agones/utils"
+)
+
+// IdleAction is the action applied to an allocated server left without players.
+type IdleAction string
+
+// Supported idle actions.
+const (
+	IdleActionReady    IdleAction = "ready"    // Reset the server and return it to Ready for a new allocation
+	IdleActionShutdown IdleAction = "shutdown" // Shut the server down
+)
+
+// ParseIdleAction validates an idle action name.
+func ParseIdleAction(name string) (IdleAction, error) {
+	switch a := IdleAction(name); a {
+	case IdleActionReady, IdleActionShutdown:
+		return a, nil
+	default:
+		return "", fmt.Errorf("unknown idle action %q", name)
+	}
+}
+
+// Reasons an allocated server is considered idle.
+const (
+	IdleReasonNeverJoined = "never_joined" // No player joined since the allocation
+	IdleReasonEmptied     = "emptied"      // The last player left
+)
+
+// IdleConfig configures the idle policy. A zero timeout disables the corresponding check.
+type IdleConfig struct {
+	Action             IdleAction
+	NeverJoinedTimeout time.Duration // Time after allocation without any player joining
+	EmptyTimeout       time.Duration // Time without players after the last one left
+	Reset              func() error  // Resets the game server before returning to Ready, may be nil
+	Shutdown           func()        // Shuts the server down gracefully
+
+	// ServerReady is signalled when the server reports ready. A reset server returns to Ready
+	// only once signalled.
+	ServerReady <-chan struct{}
+}
+
+// resetReadyTimeout bounds the time a reset server takes to report ready.
+var resetReadyTimeout = 5 * time.Minute
+
+// idleTracker follows the occupancy of an allocated server.
+type idleTracker struct {
+	allocated   bool
+	allocatedAt time.Time
+	joined      bool      // A player joined since the allocation
+	emptySince  time.Time // Time the server became empty, zero while players are connected
+}
+
+// observe records the current state and returns the idle reason once a timeout elapsed.
+func (t *idleTracker) observe(now time.Time, allocated bool, players int, config IdleConfig) string {
+	if !allocated {
+		*t = idleTracker{}
+		return ""
+	}
+	if !t.allocated {
+		*t = idleTracker{allocated: true, allocatedAt: now}
+	}
+
+	if players > 0 {
+		t.joined = true
+		t.emptySince = time.Time{}
+		return ""
+	}
+	if t.emptySince.IsZero() {
+		t.emptySince = now
+	}
+
+	switch {
+	case !t.joined && config.NeverJoinedTimeout > 0 && now.Sub(t.allocatedAt) >= config.NeverJoinedTimeout:
+		return IdleReasonNeverJoined
+	case t.joined && config.EmptyTimeout > 0 && now.Sub(t.emptySince) >= config.EmptyTimeout:
+		return IdleReasonEmptied
+	}
+	return ""
+}
+
+// MonitorIdle applies the idle policy to the server while it is allocated.
+func MonitorIdle(ctx context.Context, s *sdk.SDK, state *types.ServerState, config IdleConfig) {
+	if config.NeverJoinedTimeout <= 0 && config.EmptyTimeout <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	var tracker idleTracker
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			state.RLock()
+			allocated, players, shuttingDown := state.Allocated, state.Players, state.ShuttingDown
+			labels := prometheus.Labels{
+				"server_id":   state.ServerID,
+				"server_name": state.ServerName,
+				"server_type": state.ServerType,
+			}
+			state.RUnlock()
+
+			if shuttingDown {
+				return
+			}
+
+			reason := tracker.observe(now, allocated, players, config)
+			if reason == "" {
+				continue
+			}
+
+			utils.LogEvent("IDLE", "Server idle (%s), applying the %s action", reason, config.Action)
+			outcome := applyIdleAction(ctx, s, state, config)
+
+			labels["reason"] = reason
+			labels["action"] = string(config.Action)
+			labels["outcome"] = outcome
+			metrics.IdleActionsCounter.With(labels).Inc()
+
+			// Wait for the next allocation
+			tracker = idleTracker{}
+			if config.Action == IdleActionShutdown && outcome == "success" {
+				return
+			}
+		}
+	}
+}
+
+// applyIdleAction applies the idle action and returns its outcome (success or failure).
+func applyIdleAction(ctx context.Context, s *sdk.SDK, state *types.ServerState, config IdleConfig) string {
+	if config.Action == IdleActionShutdown {
+		config.Shutdown()
+		return "success"
+	}
+
+	if config.Reset != nil {
+		// A signal left by an earlier start does not tell that the reset server is ready
+		select {
+		case <-config.ServerReady:
+		default:
+		}
+
+		if err := config.Reset(); err != nil {
+			utils.LogError("Failed to reset idle server: %v", err)
+			return "failure"
+		}
+		if config.ServerReady != nil {
+			select {
+			case <-config.ServerReady:
+			case <-time.After(resetReadyTimeout):
+				utils.LogError("Idle server not ready %v after the reset, keeping it allocated", resetReadyTimeout)
+				return "failure"
+			case <-ctx.Done():
+				return "failure"
+			}
+		}
+	}
+	if err := s.Ready(); err != nil {
+		utils.LogError("Failed to return idle server to Ready: %v", err)
+		return "failure"
+	}
+
+	state.Lock()
+	state.Allocated = false
+	state.Unlock()
+	return "success"
+}
@@ -0,0 +1,64 @@
+package monitoring
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"agones/types"
+)
+
+func TestIdleResetWaitsForReady(t *testing.T) {
+	defer func(timeout time.Duration) { resetReadyTimeout = timeout }(resetReadyTimeout)
+	resetReadyTimeout = 50 * time.Millisecond
+
+	// Left by the previous start of the server
+	serverReady := make(chan struct{}, 1)
+	serverReady <- struct{}{}
+
+	resets := 0
+	state := &types.ServerState{Allocated: true}
+	outcome := applyIdleAction(context.Background(), nil, state, IdleConfig{
+		Action: IdleActionReady,
+		Reset: func() error {
+			resets++
+			return nil
+		},
+		ServerReady: serverReady,
+	})
+
+	if outcome != "failure" || resets != 1 {
+		t.Errorf("outcome = %q after %d resets, want a failure after 1 reset", outcome, resets)
+	}
+	if !state.Allocated {
+		t.Error("server returned to Ready before the reset server reported ready")
+	}
+}
+
+func TestIdleTracker(t *testing.T) {
+	config := IdleConfig{NeverJoinedTimeout: 5 * time.Minute, EmptyTimeout: time.Minute}
+	start := time.Now()
+	steps := []struct {
+		after     time.Duration
+		allocated bool
+		players   int
+		reason    string
+	}{
+		{0, false, 0, ""},
+		{time.Minute, true, 0, ""},
+		{5 * time.Minute, true, 0, ""},
+		{6 * time.Minute, true, 0, IdleReasonNeverJoined},
+		{7 * time.Minute, false, 0, ""},
+		{8 * time.Minute, true, 2, ""},
+		{20 * time.Minute, true, 0, ""},
+		{20*time.Minute + 59*time.Second, true, 0, ""},
+		{21 * time.Minute, true, 0, IdleReasonEmptied},
+	}
+
+	var tracker idleTracker
+	for _, step := range steps {
+		if reason := tracker.observe(start.Add(step.after), step.allocated, step.players, config); reason != step.reason {
+			t.Errorf("after %v: reason %q, want %q", step.after, reason, step.reason)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package monitoring
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"syscall"
+	"time"
+
+	"agones/types"
+)
+
+// LivenessConfig configures the probes that gate the Agones health pings.
+type LivenessConfig struct {
+	OutputWindow      time.Duration // Maximum time without server output, 0 disables the probe
+	RequireUpdateLoop bool          // Require the server update loop to have started
+	StartupGrace      time.Duration // Time after a process start during which only the process probe applies
+	InfoURL           string        // URL of the server /INFO endpoint, empty disables the probe
+	HTTPTimeout       time.Duration // Timeout of the /INFO request
+}
+
+// Liveness evaluates the liveness of the game process.
+type Liveness struct {
+	config LivenessConfig
+	state  *types.ServerState
+	client *http.Client
+}
+
+// probe is a single named liveness check.
+type probe struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// NewLiveness creates a Liveness checker for the given state.
+func NewLiveness(config LivenessConfig, state *types.ServerState) *Liveness {
+	return &Liveness{
+		config: config,
+		state:  state,
+		client: &http.Client{Timeout: config.HTTPTimeout},
+	}
+}
+
+// Check runs the probes in order and returns the name and error of the first failing one.
+func (l *Liveness) Check(ctx context.Context) (string, error) {
+	for _, p := range l.probes() {
+		if err := p.check(ctx); err != nil {
+			return p.name, err
+		}
+	}
+	return "", nil
+}
+
+// probes returns the probes applicable to the current state of the process.
+// Only the process probe applies during the startup grace and while a restart is pending.
+func (l *Liveness) probes() []probe {
+	probes := []probe{{"process", l.checkProcess}}
+
+	l.state.RLock()
+	inGrace := time.Since(l.state.ProcessStarted) < l.config.StartupGrace || l.state.RestartPending
+	l.state.RUnlock()
+	if inGrace {
+		return probes
+	}
+
+	if l.config.OutputWindow > 0 {
+		probes = append(probes, probe{"output", l.checkOutput})
+	}
+	if l.config.RequireUpdateLoop {
+		probes = append(probes, probe{"update_loop", l.checkUpdateLoop})
+	}
+	if l.config.InfoURL != "" {
+		probes = append(probes, probe{"http_info", l.checkInfo})
+	}
+	return probes
+}
+
+// checkProcess verifies that the game process is running, or is being restarted within the
+// restart policy: Agones must not kill the GameServer during the restart backoff.
+func (l *Liveness) checkProcess(_ context.Context) error {
+	l.state.RLock()
+	pid, unhealthy, restarting := l.state.ProcessPID, l.state.Unhealthy, l.state.RestartPending
+	l.state.RUnlock()
+
+	if unhealthy {
+		return fmt.Errorf("server process cannot be recovered")
+	}
+	if restarting {
+		return nil
+	}
+	if pid == 0 {
+		return fmt.Errorf("server process is not running")
+	}
+	// Signal 0 only checks that the process exists
+	if err := syscall.Kill(pid, 0); err != nil {
+		return fmt.Errorf("server process %d is not alive: %v", pid, err)
+	}
+	return nil
+}
+
+// checkOutput verifies that the server produced output recently.
+func (l *Liveness) checkOutput(_ context.Context) error {
+	l.state.RLock()
+	lastOutput := l.state.LastOutput
+	l.state.RUnlock()
+
+	if since := time.Since(lastOutput); since > l.config.OutputWindow {
+		return fmt.Errorf("no server output for %v", since.Round(time.Second))
+	}
+	return nil
+}
+
+// checkUpdateLoop verifies that the server update loop has started.
+func (l *Liveness) checkUpdateLoop(_ context.Context) error {
+	l.state.RLock()
+	started := l.state.UpdateLoopStarted
+	l.state.RUnlock()
+
+	if !started {
+		return fmt.Errorf("server update loop has not started")
+	}
+	return nil
+}
+
+// checkInfo verifies that the server answers on its /INFO endpoint.
+func (l *Liveness) checkInfo(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.config.InfoURL, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("/INFO request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("/INFO returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package monitoring
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"agones/types"
+)
+
+func TestLivenessRestartPending(t *testing.T) {
+	config := LivenessConfig{OutputWindow: time.Minute, RequireUpdateLoop: true, StartupGrace: 30 * time.Second}
+	tests := []struct {
+		name  string
+		state *types.ServerState
+		probe string // Failing probe, empty if the pings continue
+	}{
+		{"running", &types.ServerState{ProcessPID: os.Getpid(), LastOutput: time.Now(), UpdateLoopStarted: true}, ""},
+		{"exited", &types.ServerState{}, "process"},
+		{"restart backoff", &types.ServerState{RestartPending: true}, ""},
+		{"gave up", &types.ServerState{Unhealthy: true}, "process"},
+		{"starting", &types.ServerState{ProcessPID: os.Getpid(), ProcessStarted: time.Now()}, ""},
+		{"hung", &types.ServerState{ProcessPID: os.Getpid(), UpdateLoopStarted: true}, "output"},
+		{"no update loop", &types.ServerState{ProcessPID: os.Getpid(), LastOutput: time.Now()}, "update_loop"},
+	}
+	for _, tt := range tests {
+		probe, _ := NewLiveness(config, tt.state).Check(context.Background())
+		if probe != tt.probe {
+			t.Errorf("%s: failing probe %q, want %q", tt.name, probe, tt.probe)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package monitoring
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"agones/acapi"
+	"agones/metrics"
+	"agones/session"
+	"agones/types"
+	"agones/utils"
+)
+
+// PollServerAPI periodically queries the HTTP API of the game server and reconciles the
+// server state with it. The API is authoritative: values derived from the server output
+// are corrected, and the differences are exposed as drift metrics. Connected players missing
+// from the API are passed to disconnect, which reports them as disconnected.
+func PollServerAPI(ctx context.Context, client *acapi.Client, state *types.ServerState, sessions *session.SessionManager, interval time.Duration, disconnect func(steamID string)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	failing := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			polled := time.Now()
+			details, err := client.Details(ctx)
+			if err != nil {
+				// The API is unavailable while the server starts or restarts
+				if !failing {
+					utils.LogWarning("Failed to query server API: %v", err)
+					failing = true
+				}
+				continue
+			}
+			failing = false
+			reconcileState(state, sessions, details, polled, disconnect)
+		}
+	}
+}
+
+// reconcileState aligns the server state with the API response requested at polled, and
+// records the drift.
+func reconcileState(state *types.ServerState, sessions *session.SessionManager, details *acapi.DetailResponse, polled time.Time, disconnect func(steamID string)) {
+	drift := make(map[string]float64)
+
+	// Players no longer connected according to the API. Players who connected after the
+	// request are missing from the response, and are kept.
+	connected := make(map[string]bool)
+	for _, name := range details.ConnectedDrivers() {
+		connected[name] = true
+	}
+	var stale []string
+	recent := 0
+	state.RLock()
+	for steamID, player := range state.ConnectedPlayers {
+		switch {
+		case player.ConnectedAt.After(polled):
+			if !connected[player.Name] {
+				recent++
+			}
+		case !connected[player.Name]:
+			stale = append(stale, steamID)
+		}
+	}
+	state.RUnlock()
+	drift["connected_players"] = float64(len(stale))
+
+	// Stale players leave through the disconnection path, so that Agones player tracking
+	// and the counters see them go
+	for _, steamID := range stale {
+		disconnect(steamID)
+	}
+
+	state.Lock()
+	labels := prometheus.Labels{
+		"server_id":   state.ServerID,
+		"server_name": state.ServerName,
+		"server_type": state.ServerType,
+	}
+
+	// Player count
+	drift["players"] = float64(details.Clients + recent - state.Players)
+	state.Players = details.Clients + recent
+
+	// Track and layout
+	track, layout := splitTrack(details)
+	drift["track"] = 0
+	if state.CurrentTrack != "" && state.CurrentTrack != track {
+		drift["track"] = 1
+	}
+	state.CurrentTrack = track
+	state.CurrentLayout = layout
+
+	// Values only available from the API
+	state.SessionTimeLeft = details.TimeLeft
+	state.AirTemp = details.AmbientTemperature
+	state.TrackTemp = details.RoadTemperature
+	state.TrackGrip = details.Grip
+	state.MaxClients = details.MaxClients
+	state.CarModels = append([]string{}, details.Cars...)
+	state.Unlock()
+
+	// Session type and track, corrected through the session manager which owns the sessions.
+	// The track is not logged on session changes, so the API is its only source.
+	sessionType := acapi.SessionTypeName(details.Session)
+	drift["session_type"] = 0
+	if current := sessions.GetCurrentSession(); current != nil && (current.Type != sessionType || current.Track != track || current.Layout != layout) {
+		if current.Type != sessionType {
+			drift["session_type"] = 1
+		}
+		sessions.UpdateCurrentSession(func(session *types.Session) {
+			session.Type = sessionType
+			session.Track = track
+			session.Layout = layout
+		})
+	}
+
+	for field, value := range drift {
+		fieldLabels := copyLabels(labels)
+		fieldLabels["field"] = field
+		metrics.StateDriftGauge.With(fieldLabels).Set(value)
+		if value != 0 {
+			utils.LogDebug("Server state drift on %s corrected from API (%v)", field, value)
+			metrics.StateCorrectionsCounter.With(fieldLabels).Inc()
+		}
+	}
+}
+
+// splitTrack returns the track and layout reported by the API.
+func splitTrack(details *acapi.DetailResponse) (string, string) {
+	if details.TrackBase == "" {
+		return details.Track, ""
+	}
+	layout := strings.TrimPrefix(strings.TrimPrefix(details.Track, details.TrackBase), "-")
+	return details.TrackBase, layout
+}
@@ -0,0 +1,66 @@
+package monitoring
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"agones/acapi"
+	"agones/session"
+	"agones/types"
+)
+
+func TestReconcileState(t *testing.T) {
+	data, err := os.ReadFile("../acapi/testdata/details.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var details acapi.DetailResponse
+	if err := json.Unmarshal(data, &details); err != nil {
+		t.Fatal(err)
+	}
+
+	polled := time.Now()
+	state := &types.ServerState{
+		Players: 4,
+		ConnectedPlayers: map[string]*types.Player{
+			"76561198000000001": {Name: "Bob", SteamID: "76561198000000001"},
+			"76561198000000002": {Name: "Alice", SteamID: "76561198000000002"},
+			"76561198000000003": {Name: "Carl", SteamID: "76561198000000003"}, // Disconnect missed
+			// Connected while the API was queried
+			"76561198000000004": {Name: "Dave", SteamID: "76561198000000004", ConnectedAt: polled.Add(time.Second)},
+		},
+	}
+	sessions := session.NewSessionManager(5)
+	sessions.Mirror(state)
+	// Session changes are logged without the track, and the output said practice
+	sessions.StartNewSession(types.SessionTypePractice, "")
+
+	var disconnected []string
+	reconcileState(state, sessions, &details, polled, func(steamID string) {
+		disconnected = append(disconnected, steamID)
+		// As the disconnection path of the handlers does
+		state.Lock()
+		delete(state.ConnectedPlayers, steamID)
+		state.Players--
+		state.Unlock()
+	})
+
+	if len(disconnected) != 1 || disconnected[0] != "76561198000000003" {
+		t.Errorf("disconnected %q, want the player missing from the API", disconnected)
+	}
+	if state.Players != 3 || len(state.ConnectedPlayers) != 3 || state.ConnectedPlayers["76561198000000004"] == nil {
+		t.Errorf("players %d, connected %v, want the API players and the new player", state.Players, state.ConnectedPlayers)
+	}
+	if state.CurrentTrack != "ks_nordschleife" || state.CurrentLayout != "endurance" {
+		t.Errorf("track %q, layout %q", state.CurrentTrack, state.CurrentLayout)
+	}
+	if state.MaxClients != 24 || state.TrackGrip != 98.5 || state.SessionTimeLeft != 1642 {
+		t.Errorf("max clients %d, grip %v, time left %d", state.MaxClients, state.TrackGrip, state.SessionTimeLeft)
+	}
+	current := sessions.GetCurrentSession()
+	if current.Type != types.SessionTypeRace || current.Track != "ks_nordschleife" || current.Layout != "endurance" {
+		t.Errorf("session %q on %q (%q), want race on ks_nordschleife (endurance)", current.Type, current.Track, current.Layout)
+	}
+}
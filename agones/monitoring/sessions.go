@@ -0,0 +1,44 @@
+package monitoring
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"agones/metrics"
+	"agones/session"
+	"agones/types"
+)
+
+// MonitorSessions records the session metrics from the session transitions.
+func MonitorSessions(ctx context.Context, sessions *session.SessionManager, state *types.ServerState) {
+	transitions, unsubscribe := sessions.Subscribe(16)
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case transition, ok := <-transitions:
+			if !ok {
+				return
+			}
+
+			if ended := transition.Ended; ended != nil {
+				metrics.SessionDurationHistogram.With(prometheus.Labels{
+					"session_type": ended.Type,
+					"track":        ended.Track,
+				}).Observe(ended.EndTime.Sub(ended.StartTime).Seconds())
+			}
+			if transition.Started != nil {
+				state.RLock()
+				metrics.SessionChangeCounter.With(prometheus.Labels{
+					"server_id":   state.ServerID,
+					"server_name": state.ServerName,
+					"server_type": state.ServerType,
+				}).Inc()
+				state.RUnlock()
+			}
+		}
+	}
+}
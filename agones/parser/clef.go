@@ -0,0 +1,130 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Format identifies the format of the server output stream.
+type Format string
+
+// Supported output formats.
+const (
+	FormatText Format = "text" // Serilog console text ("[HH:mm:ss LVL] message")
+	FormatCLEF Format = "clef" // Serilog compact JSON (CLEF), one event per line
+)
+
+// ParseFormat validates a format name.
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(strings.ToLower(name)); f {
+	case FormatText, FormatCLEF:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown output format %q", name)
+	}
+}
+
+// Parse parses a line according to the format.
+// In CLEF mode, lines that are not JSON (e.g. output of the start script) are parsed as text.
+func (f Format) Parse(line string) Entry {
+	if f == FormatCLEF {
+		if entry, err := ParseCLEF(line); err == nil {
+			return entry
+		}
+	}
+	return ParseText(line)
+}
+
+// clefLevels maps CLEF level names to the abbreviations used in the text format.
+var clefLevels = map[string]string{
+	"Verbose":     LevelVerbose,
+	"Debug":       LevelDebug,
+	"Information": LevelInfo,
+	"Warning":     LevelWarning,
+	"Error":       LevelError,
+	"Fatal":       LevelFatal,
+}
+
+// templateHole matches a message template property such as {ClientName}, {@Lap} or {LapTime:l}.
+var templateHole = regexp.MustCompile(`\{[@$]?([A-Za-z0-9_]+)(?:,[^:}]*)?(?::[^}]*)?\}`)
+
+// ParseCLEF parses a line in the Serilog compact JSON format.
+// The returned entry carries the event properties, and a Raw line rendered in the
+// text format so that output rules behave identically for both formats.
+func ParseCLEF(line string) (Entry, error) {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "{") {
+		return Entry{}, fmt.Errorf("not a JSON object")
+	}
+
+	// Numbers are kept as json.Number so that Steam IDs keep their precision
+	decoder := json.NewDecoder(strings.NewReader(trimmed))
+	decoder.UseNumber()
+
+	var event map[string]interface{}
+	if err := decoder.Decode(&event); err != nil {
+		return Entry{}, fmt.Errorf("invalid CLEF event: %v", err)
+	}
+
+	entry := Entry{
+		Timestamp:  time.Now(),
+		Level:      LevelInfo, // CLEF omits the level for Information events
+		Properties: make(map[string]interface{}),
+	}
+
+	for key, value := range event {
+		if !strings.HasPrefix(key, "@") {
+			entry.Properties[key] = value
+		}
+	}
+
+	if t, ok := event["@t"].(string); ok {
+		if ts, err := time.Parse(time.RFC3339Nano, t); err == nil {
+			entry.Timestamp = ts
+		}
+	}
+	if l, ok := event["@l"].(string); ok {
+		if level, ok := clefLevels[l]; ok {
+			entry.Level = level
+		}
+	}
+	if x, ok := event["@x"].(string); ok {
+		entry.Exception = x
+	}
+
+	if m, ok := event["@m"].(string); ok {
+		entry.Message = m
+	} else if mt, ok := event["@mt"].(string); ok {
+		entry.Message = entry.render(mt)
+	}
+
+	entry.Raw = fmt.Sprintf("[%s %s] %s", entry.Timestamp.Local().Format("15:04:05"), entry.Level, entry.Message)
+	return entry, nil
+}
+
+// Property returns the first non-empty property among names, formatted as a string.
+func (e Entry) Property(names ...string) string {
+	for _, name := range names {
+		if value, ok := e.Properties[name]; ok && value != nil {
+			if str := fmt.Sprintf("%v", value); str != "" {
+				return str
+			}
+		}
+	}
+	return ""
+}
+
+// render substitutes the properties of the entry into a message template.
+func (e Entry) render(template string) string {
+	rendered := templateHole.ReplaceAllStringFunc(template, func(hole string) string {
+		name := templateHole.FindStringSubmatch(hole)[1]
+		if value, ok := e.Properties[name]; ok {
+			return fmt.Sprintf("%v", value)
+		}
+		return hole
+	})
+	return strings.NewReplacer("{{", "{", "}}", "}").Replace(rendered)
+}
@@ -0,0 +1,57 @@
+package parser
+
+import "testing"
+
+func TestParseCLEF(t *testing.T) {
+	line := `{"@t":"2024-05-10T12:01:00.1234567Z","@mt":"{ClientName} ({ClientSteamId}, {SessionId} ({CarModel}-{CarSkin})) has connected","ClientName":"Bob","ClientSteamId":76561198000000001,"SessionId":0,"CarModel":"ks_mazda_miata","CarSkin":"red"}`
+
+	entry, err := ParseCLEF(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Level != LevelInfo {
+		t.Errorf("level = %q, want %q", entry.Level, LevelInfo)
+	}
+	if want := "Bob (76561198000000001, 0 (ks_mazda_miata-red)) has connected"; entry.Message != want {
+		t.Errorf("message = %q, want %q", entry.Message, want)
+	}
+	// Steam IDs exceed the float64 precision
+	if got := entry.Property("ClientSteamId", "SteamId"); got != "76561198000000001" {
+		t.Errorf("ClientSteamId = %q", got)
+	}
+	if got := entry.Property("Missing", "ClientName"); got != "Bob" {
+		t.Errorf("Property fallback = %q, want Bob", got)
+	}
+}
+
+func TestParseCLEFLevels(t *testing.T) {
+	tests := []struct {
+		line    string
+		level   string
+		message string
+	}{
+		{`{"@t":"2024-05-10T12:00:00Z","@mt":"Steam authentication failed for {ClientName} ({SessionId}): {ErrorReason}","@l":"Warning","ClientName":"Bob","SessionId":3,"ErrorReason":"AuthTicketInvalid"}`,
+			LevelWarning, "Steam authentication failed for Bob (3): AuthTicketInvalid"},
+		{`{"@t":"2024-05-10T12:00:00Z","@m":"Lobby registration successful"}`, LevelInfo, "Lobby registration successful"},
+		{`{"@t":"2024-05-10T12:00:00Z","@mt":"Unknown {Hole} {{literal}}","@l":"Error"}`, LevelError, "Unknown {Hole} {literal}"},
+	}
+	for _, tt := range tests {
+		entry, err := ParseCLEF(tt.line)
+		if err != nil {
+			t.Fatalf("ParseCLEF(%s): %v", tt.line, err)
+		}
+		if entry.Level != tt.level || entry.Message != tt.message {
+			t.Errorf("ParseCLEF(%s) = %q, %q, want %q, %q", tt.line, entry.Level, entry.Message, tt.level, tt.message)
+		}
+	}
+}
+
+func TestFormatParseFallback(t *testing.T) {
+	entry := FormatCLEF.Parse("Starting server with config /shared-config")
+	if entry.Properties != nil || entry.Message != "Starting server with config /shared-config" {
+		t.Errorf("non-JSON line parsed as %+v", entry)
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("ParseFormat accepted an unknown format")
+	}
+}
@@ -0,0 +1,80 @@
+// Package parser turns the raw AssettoServer output stream into structured log entries.
+package parser
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+
+	"agones/utils"
+)
+
+// DefaultMaxLineLength is the default maximum length of a single line.
+const DefaultMaxLineLength = 64 * 1024
+
+// LineWriter is an io.Writer that reassembles the chunks written by a child process
+// into complete lines. Partial writes are buffered until their newline arrives and
+// chunks containing several lines are split, so every line is emitted exactly once.
+type LineWriter struct {
+	sync.Mutex
+	buf           []byte
+	maxLineLength int               // Lines longer than this are emitted in several parts
+	emit          func(line string) // Called for every complete line
+}
+
+// NewLineWriter creates a LineWriter calling emit for every complete line.
+func NewLineWriter(emit func(line string)) *LineWriter {
+	return &LineWriter{
+		maxLineLength: DefaultMaxLineLength,
+		emit:          emit,
+	}
+}
+
+// Write buffers p and emits every line it completes. It never fails.
+func (w *LineWriter) Write(p []byte) (int, error) {
+	w.Lock()
+	defer w.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		idx := bytes.IndexByte(w.buf, '\n')
+		if idx == -1 {
+			break
+		}
+		w.emitLine(w.buf[:idx])
+		w.buf = w.buf[idx+1:]
+	}
+
+	// Never let a runaway line grow the buffer without bound
+	if len(w.buf) > w.maxLineLength {
+		utils.LogWarning("Server output line exceeds %d bytes, splitting it", w.maxLineLength)
+		w.emitLine(w.buf)
+		w.buf = nil
+	}
+
+	// Release the backing array once fully consumed
+	if len(w.buf) == 0 {
+		w.buf = nil
+	}
+	return len(p), nil
+}
+
+// Flush emits any buffered partial line. It should be called once the stream ends.
+func (w *LineWriter) Flush() {
+	w.Lock()
+	defer w.Unlock()
+
+	if len(w.buf) > 0 {
+		w.emitLine(w.buf)
+		w.buf = nil
+	}
+}
+
+// emitLine strips line terminators and passes non-empty lines to the callback.
+func (w *LineWriter) emitLine(line []byte) {
+	str := strings.TrimRight(string(line), "\r\n")
+	if strings.TrimSpace(str) == "" {
+		return
+	}
+	w.emit(str)
+}
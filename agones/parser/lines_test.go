@@ -0,0 +1,57 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLineWriter(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+		want   []string
+	}{
+		{"single line", []string{"[12:00:00 INF] Lobby registration successful\n"}, []string{"[12:00:00 INF] Lobby registration successful"}},
+		{"split line", []string{"[12:00:00 INF] Lap completed by Bob, ", "0 cuts, laptime 01:32.4560\n"}, []string{"[12:00:00 INF] Lap completed by Bob, 0 cuts, laptime 01:32.4560"}},
+		{"several lines", []string{"a\nb\nc"}, []string{"a", "b"}},
+		{"CRLF", []string{"a\r\nb\r\n"}, []string{"a", "b"}},
+		{"blank lines", []string{"\n  \na\n\n"}, []string{"a"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		w := NewLineWriter(func(line string) { got = append(got, line) })
+		for _, chunk := range tt.chunks {
+			if n, err := w.Write([]byte(chunk)); n != len(chunk) || err != nil {
+				t.Fatalf("%s: Write = %d, %v", tt.name, n, err)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: lines = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLineWriterFlush(t *testing.T) {
+	var got []string
+	w := NewLineWriter(func(line string) { got = append(got, line) })
+	w.Write([]byte("complete\npartial"))
+	w.Flush()
+	w.Flush()
+
+	if want := []string{"complete", "partial"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("lines = %q, want %q", got, want)
+	}
+}
+
+func TestLineWriterMaxLength(t *testing.T) {
+	var got []string
+	w := NewLineWriter(func(line string) { got = append(got, line) })
+	w.maxLineLength = 8
+	w.Write([]byte(strings.Repeat("x", 10)))
+	w.Write([]byte("yz\n"))
+
+	if want := []string{strings.Repeat("x", 10), "yz"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("lines = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,68 @@
+package parser
+
+import (
+	"regexp"
+	"time"
+)
+
+// Serilog level abbreviations used by the AssettoServer console output.
+const (
+	LevelVerbose = "VRB"
+	LevelDebug   = "DBG"
+	LevelInfo    = "INF"
+	LevelWarning = "WRN"
+	LevelError   = "ERR"
+	LevelFatal   = "FTL"
+)
+
+// Entry is a single parsed line of server output.
+type Entry struct {
+	Timestamp time.Time // Time the line was logged, or received if the line has no prefix
+	Level     string    // Serilog level abbreviation, empty for continuation lines (e.g. stack traces)
+	Message   string    // Message without the Serilog prefix
+	Raw       string    // Complete line as written by the server
+
+	Properties map[string]interface{} // Structured event properties (CLEF only)
+	Exception  string                 // Exception details attached to the event (CLEF only)
+}
+
+// serilogPrefix matches the default Serilog console prefix: "[HH:mm:ss LVL] message".
+var serilogPrefix = regexp.MustCompile(`^\[(\d{2}:\d{2}:\d{2}) ([A-Z]{3})\] ?(.*)$`)
+
+// ParseText parses a line in the Serilog text format.
+// Lines without a prefix are returned with an empty level and the receive time.
+func ParseText(line string) Entry {
+	now := time.Now()
+	entry := Entry{
+		Timestamp: now,
+		Message:   line,
+		Raw:       line,
+	}
+
+	m := serilogPrefix.FindStringSubmatch(line)
+	if m == nil {
+		return entry
+	}
+
+	entry.Level = m[2]
+	entry.Message = m[3]
+	if ts, ok := parseClock(m[1], now); ok {
+		entry.Timestamp = ts
+	}
+	return entry
+}
+
+// parseClock resolves a HH:mm:ss time of day to the closest matching instant before now.
+func parseClock(clock string, now time.Time) (time.Time, bool) {
+	t, err := time.ParseInLocation("15:04:05", clock, now.Location())
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	ts := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), t.Second(), 0, now.Location())
+	// A time of day far in the future was logged just before midnight
+	if ts.Sub(now) > time.Hour {
+		ts = ts.AddDate(0, 0, -1)
+	}
+	return ts, true
+}
@@ -0,0 +1,47 @@
+package parser
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseText(t *testing.T) {
+	tests := []struct {
+		line    string
+		level   string
+		message string
+	}{
+		{"[12:00:01 INF] Starting update loop with an update rate of 18hz", LevelInfo, "Starting update loop with an update rate of 18hz"},
+		{"[12:00:02 WRN] Steam authentication failed for Bob (0): AuthTicketInvalid", LevelWarning, "Steam authentication failed for Bob (0): AuthTicketInvalid"},
+		{"[12:00:03 ERR]", LevelError, ""},
+		{"   at AssettoServer.Program.Main()", "", "   at AssettoServer.Program.Main()"},
+		{"Starting server with config /shared-config", "", "Starting server with config /shared-config"},
+	}
+	for _, tt := range tests {
+		entry := ParseText(tt.line)
+		if entry.Level != tt.level || entry.Message != tt.message || entry.Raw != tt.line {
+			t.Errorf("ParseText(%q) = level %q, message %q, raw %q", tt.line, entry.Level, entry.Message, entry.Raw)
+		}
+	}
+}
+
+func TestParseClock(t *testing.T) {
+	now := time.Date(2024, 5, 10, 0, 0, 30, 0, time.UTC)
+	tests := []struct {
+		clock string
+		want  time.Time
+	}{
+		{"00:00:10", time.Date(2024, 5, 10, 0, 0, 10, 0, time.UTC)},
+		// Logged just before midnight, received just after
+		{"23:59:58", time.Date(2024, 5, 9, 23, 59, 58, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, ok := parseClock(tt.clock, now)
+		if !ok || !got.Equal(tt.want) {
+			t.Errorf("parseClock(%q) = %v, %v, want %v", tt.clock, got, ok, tt.want)
+		}
+	}
+	if _, ok := parseClock("25:00:00", now); ok {
+		t.Error("parseClock accepted an invalid time")
+	}
+}
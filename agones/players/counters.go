@@ -0,0 +1,290 @@
+package players
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	sdk "agones.dev/agones/sdks/go"
+
+	"agones/types"
+	"agones/utils"
+)
+
+// Keys of the Agones counters and lists maintained by Counters.
+const (
+	CounterPlayers   = "players"    // Number of connected players
+	CounterFreeSlots = "free_slots" // Number of slots players can still join
+	ListCars         = "cars"       // Car models with at least one free slot
+)
+
+// Counters maintains the Agones counters and lists the allocator filters on.
+// The counters and lists must be declared in the GameServer spec.
+// Updates are applied in the background by Run, the latest one replacing those not applied yet.
+// A nil Counters is valid and does nothing.
+type Counters struct {
+	alpha         *sdk.Alpha
+	entryListPath string
+	pending       chan counterUpdate // Latest update not applied yet
+
+	mu        sync.Mutex
+	failed    map[string]bool // Keys whose last update failed, to log failures once
+	slots     []EntrySlot     // Entry list slots, read again when the file changes
+	slotsInfo os.FileInfo     // Entry list file the slots were read from
+}
+
+// counterUpdate is the part of the server state the counters and lists are computed from.
+type counterUpdate struct {
+	players    int64
+	maxClients int64
+	aiSlots    int // Slots without a player driven by the AI, -1 when unknown
+	draining   bool
+	carModels  []string
+}
+
+// errUnknownCapacity is reported while neither the server nor the entry list gave the capacity.
+var errUnknownCapacity = errors.New("player capacity unknown")
+
+// NewCounters creates Counters using the Alpha SDK.
+func NewCounters(alpha *sdk.Alpha, entryListPath string) *Counters {
+	return &Counters{
+		alpha:         alpha,
+		entryListPath: entryListPath,
+		pending:       make(chan counterUpdate, 1),
+		failed:        make(map[string]bool),
+	}
+}
+
+// Update schedules the update of the counters and lists from the server state.
+// It does not block, the update is applied by Run.
+func (c *Counters) Update(state *types.ServerState) {
+	if c == nil {
+		return
+	}
+
+	state.RLock()
+	update := counterUpdate{
+		players:    int64(state.Players),
+		maxClients: int64(state.MaxClients),
+		aiSlots:    -1,
+		draining:   state.Draining,
+		carModels:  make([]string, 0, len(state.ConnectedPlayers)),
+	}
+	if total, ok := state.ActiveCars["total"]; ok {
+		update.aiSlots = total
+	}
+	for _, player := range state.ConnectedPlayers {
+		update.carModels = append(update.carModels, player.CarModel)
+	}
+	state.RUnlock()
+
+	// Replace the update not applied yet, if any
+	for {
+		select {
+		case c.pending <- update:
+			return
+		default:
+		}
+		select {
+		case <-c.pending:
+		default:
+		}
+	}
+}
+
+// Run applies the updates until ctx is cancelled.
+func (c *Counters) Run(ctx context.Context) {
+	if c == nil {
+		return
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case update := <-c.pending:
+			c.apply(update)
+		}
+	}
+}
+
+// apply sets the counters and lists.
+func (c *Counters) apply(update counterUpdate) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	slots := c.entrySlots()
+	playerSlots := PlayerSlots(slots)
+	capacity := update.maxClients
+	if capacity <= 0 {
+		capacity = int64(len(playerSlots))
+	}
+	free := freeSlots(capacity, update.players, update.aiSlots, slots)
+	models := freeCarModels(playerSlots, update.carModels)
+	// A draining server no longer takes players
+	if update.draining {
+		free, models = 0, nil
+	}
+
+	if capacity <= 0 {
+		c.report(CounterPlayers, errUnknownCapacity)
+		c.report(CounterFreeSlots, errUnknownCapacity)
+	} else {
+		c.setCounter(CounterPlayers, update.players, capacity)
+		c.setCounter(CounterFreeSlots, free, capacity)
+	}
+	c.setList(ListCars, models)
+}
+
+// entrySlots returns the slots of the entry list, read again only when the file changed.
+func (c *Counters) entrySlots() []EntrySlot {
+	info, err := os.Stat(c.entryListPath)
+	if err != nil {
+		utils.LogDebug("Failed to read entry list: %v", err)
+		c.slots, c.slotsInfo = nil, nil
+		return nil
+	}
+	if c.slotsInfo != nil && info.ModTime().Equal(c.slotsInfo.ModTime()) && info.Size() == c.slotsInfo.Size() {
+		return c.slots
+	}
+
+	slots, err := ReadEntryList(c.entryListPath)
+	if err != nil {
+		utils.LogDebug("Failed to read entry list: %v", err)
+		return c.slots
+	}
+	c.slots, c.slotsInfo = slots, info
+	return c.slots
+}
+
+// freeSlots returns the number of slots players can still join. When every player slot is
+// in AI auto mode, the free slots are those the server reports as driven by the AI, less the
+// slots reserved for AI, which also accounts for the players still connecting.
+func freeSlots(capacity, players int64, aiSlots int, slots []EntrySlot) int64 {
+	free := capacity - players
+	playerSlots := PlayerSlots(slots)
+	if aiSlots >= 0 && len(playerSlots) > 0 && allAuto(playerSlots) {
+		if auto := int64(aiSlots - (len(slots) - len(playerSlots))); auto < free {
+			free = auto
+		}
+	}
+	if free < 0 {
+		free = 0
+	}
+	return free
+}
+
+// allAuto returns whether every slot may be taken by the AI while no player is using it.
+func allAuto(slots []EntrySlot) bool {
+	for _, slot := range slots {
+		if slot.AI != "auto" {
+			return false
+		}
+	}
+	return true
+}
+
+// setCounter sets the count and capacity of a counter.
+func (c *Counters) setCounter(key string, count, capacity int64) {
+	if _, err := c.alpha.SetCounterCapacity(key, capacity); err != nil {
+		c.report(key, err)
+		return
+	}
+	if _, err := c.alpha.SetCounterCount(key, count); err != nil {
+		c.report(key, err)
+		return
+	}
+	c.report(key, nil)
+}
+
+// setList replaces the values of a list.
+func (c *Counters) setList(key string, values []string) {
+	current, err := c.alpha.GetListValues(key)
+	if err != nil {
+		c.report(key, err)
+		return
+	}
+
+	wanted := make(map[string]bool, len(values))
+	for _, value := range values {
+		wanted[value] = true
+	}
+	for _, value := range current {
+		if wanted[value] {
+			delete(wanted, value)
+			continue
+		}
+		if _, err := c.alpha.DeleteListValue(key, value); err != nil {
+			c.report(key, err)
+			return
+		}
+	}
+
+	if len(values) > 0 {
+		if _, err := c.alpha.SetListCapacity(key, int64(len(values))); err != nil {
+			c.report(key, err)
+			return
+		}
+	}
+	for _, value := range values {
+		if !wanted[value] {
+			continue
+		}
+		if _, err := c.alpha.AppendListValue(key, value); err != nil {
+			c.report(key, err)
+			return
+		}
+	}
+	c.report(key, nil)
+}
+
+// report logs the first failure of a key and its recovery.
+func (c *Counters) report(key string, err error) {
+	switch {
+	case err != nil && !c.failed[key]:
+		utils.LogWarning("Failed to update Agones %s: %v", key, err)
+		c.failed[key] = true
+	case err == nil && c.failed[key]:
+		utils.LogSDK("Agones %s updated again", key)
+		delete(c.failed, key)
+	}
+}
+
+// freeCarModels returns the sorted car models of the slots not taken by the connected players.
+// Connected car models may carry the skin as a suffix (model-skin).
+func freeCarModels(slots []EntrySlot, connected []string) []string {
+	free := make(map[string]int)
+	for _, slot := range slots {
+		if slot.Model != "" {
+			free[slot.Model]++
+		}
+	}
+	for _, carModel := range connected {
+		if model := matchModel(free, carModel); model != "" {
+			free[model]--
+		}
+	}
+
+	var models []string
+	for model, count := range free {
+		if count > 0 {
+			models = append(models, model)
+		}
+	}
+	sort.Strings(models)
+	return models
+}
+
+// matchModel returns the longest model of models that carModel is or starts with.
+func matchModel(models map[string]int, carModel string) string {
+	match := ""
+	for model := range models {
+		if (carModel == model || strings.HasPrefix(carModel, model+"-")) && len(model) > len(match) {
+			match = model
+		}
+	}
+	return match
+}
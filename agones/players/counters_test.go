@@ -0,0 +1,67 @@
+package players
+
+import (
+	"reflect"
+	"testing"
+
+	"agones/types"
+)
+
+func TestFreeSlots(t *testing.T) {
+	auto := []EntrySlot{{Model: "a", AI: "auto"}, {Model: "a", AI: "auto"}, {Model: "b", AI: "auto"}, {Model: "c", AI: "fixed"}}
+	mixed := []EntrySlot{{Model: "a", AI: "none"}, {Model: "a", AI: "auto"}, {Model: "b", AI: "none"}}
+
+	tests := []struct {
+		name     string
+		capacity int64
+		players  int64
+		aiSlots  int
+		slots    []EntrySlot
+		want     int64
+	}{
+		{"no entry list", 8, 3, -1, nil, 5},
+		{"full", 2, 3, -1, nil, 0},
+		{"AI slots unknown", 3, 1, -1, auto, 2},
+		// One player connected and one connecting: two slots and the fixed one without a player
+		{"AI slots", 3, 1, 2, auto, 1},
+		{"AI slots behind a disconnection", 3, 0, 2, auto, 1},
+		{"mixed AI modes", 3, 1, 1, mixed, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := freeSlots(tt.capacity, tt.players, tt.aiSlots, tt.slots); got != tt.want {
+				t.Errorf("freeSlots = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFreeCarModels(t *testing.T) {
+	slots := []EntrySlot{{Model: "ks_mazda_miata"}, {Model: "ks_mazda_miata"}, {Model: "bmw_m3_e30"}, {Model: "bmw_m3_e30_drift"}}
+	got := freeCarModels(slots, []string{"ks_mazda_miata-red", "bmw_m3_e30_drift", "unknown"})
+	want := []string{"bmw_m3_e30", "ks_mazda_miata"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("freeCarModels = %q, want %q", got, want)
+	}
+}
+
+func TestUpdateKeepsLatest(t *testing.T) {
+	c := NewCounters(nil, "")
+	state := &types.ServerState{ActiveCars: map[string]int{"total": 4}}
+
+	state.Players = 1
+	c.Update(state)
+	state.Players = 2
+	state.Draining = true
+	c.Update(state)
+
+	update := <-c.pending
+	if update.players != 2 || update.aiSlots != 4 || !update.draining {
+		t.Errorf("pending update = %+v, want 2 players, 4 AI slots and draining", update)
+	}
+	select {
+	case update := <-c.pending:
+		t.Errorf("superseded update %+v still pending", update)
+	default:
+	}
+}
@@ -0,0 +1,63 @@
+package players
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// EntrySlot is a car slot ([CAR_n] section) of an entry_list.ini file.
+type EntrySlot struct {
+	Model string // Car model
+	AI    string // AI mode of the slot (none, auto or fixed)
+}
+
+// ReadEntryList reads the car slots of an entry_list.ini file.
+func ReadEntryList(path string) ([]EntrySlot, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var slots []EntrySlot
+	var slot *EntrySlot
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			slot = nil
+			if strings.HasPrefix(strings.ToUpper(line), "[CAR_") {
+				slots = append(slots, EntrySlot{})
+				slot = &slots[len(slots)-1]
+			}
+			continue
+		}
+		if slot == nil {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		switch strings.ToUpper(strings.TrimSpace(key)) {
+		case "MODEL":
+			slot.Model = strings.TrimSpace(value)
+		case "AI":
+			slot.AI = strings.ToLower(strings.TrimSpace(value))
+		}
+	}
+	return slots, scanner.Err()
+}
+
+// PlayerSlots returns the slots players can join, i.e. the ones not reserved for AI.
+func PlayerSlots(slots []EntrySlot) []EntrySlot {
+	var playerSlots []EntrySlot
+	for _, slot := range slots {
+		if slot.AI != "fixed" {
+			playerSlots = append(playerSlots, slot)
+		}
+	}
+	return playerSlots
+}
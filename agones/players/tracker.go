@@ -0,0 +1,120 @@
+// Package players mirrors the players connected to the game server into Agones player tracking,
+// counters and lists.
+package players
+
+import (
+	"sync"
+
+	sdk "agones.dev/agones/sdks/go"
+
+	"agones/types"
+	"agones/utils"
+)
+
+// playerTracking is the player tracking part of the Alpha SDK.
+type playerTracking interface {
+	PlayerConnect(id string) (bool, error)
+	PlayerDisconnect(id string) (bool, error)
+	SetPlayerCapacity(capacity int64) error
+	GetConnectedPlayers() ([]string, error)
+}
+
+// Tracker reports player connections and the player capacity through the Alpha SDK.
+// A nil Tracker is valid and does nothing, which is the case when player tracking is disabled.
+type Tracker struct {
+	alpha         playerTracking
+	entryListPath string // entry_list.ini used for the capacity when the server API is unavailable
+
+	mu       sync.Mutex
+	capacity int64 // Last capacity reported to Agones
+}
+
+// NewTracker creates a Tracker using the Alpha SDK.
+func NewTracker(alpha *sdk.Alpha, entryListPath string) *Tracker {
+	return &Tracker{
+		alpha:         alpha,
+		entryListPath: entryListPath,
+	}
+}
+
+// Connect reports a player connection, identified by Steam ID.
+func (t *Tracker) Connect(steamID string) {
+	if t == nil || steamID == "" {
+		return
+	}
+	if _, err := t.alpha.PlayerConnect(steamID); err != nil {
+		utils.LogWarning("Failed to report player connection of %s: %v", steamID, err)
+	}
+}
+
+// Disconnect reports a player disconnection, identified by Steam ID.
+func (t *Tracker) Disconnect(steamID string) {
+	if t == nil || steamID == "" {
+		return
+	}
+	if _, err := t.alpha.PlayerDisconnect(steamID); err != nil {
+		utils.LogWarning("Failed to report player disconnection of %s: %v", steamID, err)
+	}
+}
+
+// Reconcile updates the player capacity and aligns the Agones player list with the
+// players connected according to the server state.
+func (t *Tracker) Reconcile(state *types.ServerState) {
+	if t == nil {
+		return
+	}
+
+	state.RLock()
+	capacity := int64(state.MaxClients)
+	connected := make(map[string]bool, len(state.ConnectedPlayers))
+	for steamID := range state.ConnectedPlayers {
+		connected[steamID] = true
+	}
+	state.RUnlock()
+
+	t.updateCapacity(capacity)
+
+	tracked, err := t.alpha.GetConnectedPlayers()
+	if err != nil {
+		utils.LogWarning("Failed to get connected players from Agones: %v", err)
+		return
+	}
+
+	for _, steamID := range tracked {
+		if connected[steamID] {
+			delete(connected, steamID)
+			continue
+		}
+		utils.LogDebug("Removing stale player %s from Agones player tracking", steamID)
+		t.Disconnect(steamID)
+	}
+	for steamID := range connected {
+		utils.LogDebug("Adding missing player %s to Agones player tracking", steamID)
+		t.Connect(steamID)
+	}
+}
+
+// updateCapacity reports the player capacity, read from the entry list when the server
+// did not report its maximum number of clients.
+func (t *Tracker) updateCapacity(capacity int64) {
+	if capacity <= 0 {
+		slots, err := ReadEntryList(t.entryListPath)
+		if err != nil {
+			utils.LogDebug("Player capacity unknown: %v", err)
+			return
+		}
+		capacity = int64(len(PlayerSlots(slots)))
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if capacity == t.capacity {
+		return
+	}
+	if err := t.alpha.SetPlayerCapacity(capacity); err != nil {
+		utils.LogWarning("Failed to set player capacity: %v", err)
+		return
+	}
+	utils.LogSDK("Player capacity set to %d", capacity)
+	t.capacity = capacity
+}
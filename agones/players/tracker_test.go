@@ -0,0 +1,149 @@
+package players
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"agones/types"
+)
+
+// fakeAlpha tracks the players as the Agones SDK server does.
+type fakeAlpha struct {
+	players    map[string]bool
+	capacity   int64
+	capacities int // Number of capacity updates
+	calls      int // Number of connections and disconnections reported
+}
+
+func newFakeAlpha(players ...string) *fakeAlpha {
+	a := &fakeAlpha{players: make(map[string]bool)}
+	for _, id := range players {
+		a.players[id] = true
+	}
+	return a
+}
+
+func (a *fakeAlpha) PlayerConnect(id string) (bool, error) {
+	a.calls++
+	if a.players[id] {
+		return false, nil
+	}
+	a.players[id] = true
+	return true, nil
+}
+
+func (a *fakeAlpha) PlayerDisconnect(id string) (bool, error) {
+	a.calls++
+	if !a.players[id] {
+		return false, nil
+	}
+	delete(a.players, id)
+	return true, nil
+}
+
+func (a *fakeAlpha) SetPlayerCapacity(capacity int64) error {
+	a.capacity = capacity
+	a.capacities++
+	return nil
+}
+
+func (a *fakeAlpha) GetConnectedPlayers() ([]string, error) {
+	var ids []string
+	for id := range a.players {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func TestTrackerConnect(t *testing.T) {
+	alpha := newFakeAlpha()
+	tracker := &Tracker{alpha: alpha}
+
+	tracker.Connect("76561198000000001")
+	tracker.Connect("76561198000000001")
+	tracker.Connect("")
+	tracker.Disconnect("76561198000000002")
+	if got, _ := alpha.GetConnectedPlayers(); !reflect.DeepEqual(got, []string{"76561198000000001"}) {
+		t.Errorf("tracked %q after repeated connections", got)
+	}
+
+	tracker.Disconnect("76561198000000001")
+	tracker.Disconnect("76561198000000001")
+	if got, _ := alpha.GetConnectedPlayers(); len(got) != 0 {
+		t.Errorf("tracked %q after repeated disconnections", got)
+	}
+	if alpha.calls != 5 {
+		t.Errorf("%d calls, want the empty Steam ID to be skipped", alpha.calls)
+	}
+}
+
+func TestTrackerReconcile(t *testing.T) {
+	alpha := newFakeAlpha("76561198000000001", "76561198000000003")
+	tracker := &Tracker{alpha: alpha}
+	state := &types.ServerState{
+		MaxClients: 12,
+		ConnectedPlayers: map[string]*types.Player{
+			"76561198000000001": {Name: "Bob"},
+			"76561198000000002": {Name: "Alice"},
+		},
+	}
+
+	tracker.Reconcile(state)
+	want := []string{"76561198000000001", "76561198000000002"}
+	if got, _ := alpha.GetConnectedPlayers(); !reflect.DeepEqual(got, want) {
+		t.Errorf("tracked %q, want %q", got, want)
+	}
+	if alpha.capacity != 12 {
+		t.Errorf("capacity %d, want 12", alpha.capacity)
+	}
+
+	// An aligned list and an unchanged capacity are left alone
+	calls, capacities := alpha.calls, alpha.capacities
+	tracker.Reconcile(state)
+	if alpha.calls != calls || alpha.capacities != capacities {
+		t.Errorf("%d calls and %d capacity updates on an aligned tracker", alpha.calls-calls, alpha.capacities-capacities)
+	}
+}
+
+func TestTrackerCapacityFromEntryList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "entry_list.ini")
+	entryList := `[CAR_0]
+MODEL=ks_mazda_miata
+[CAR_1]
+MODEL=ks_mazda_miata
+AI=auto
+[CAR_2]
+MODEL=bmw_m3_e30
+AI=fixed
+`
+	if err := os.WriteFile(path, []byte(entryList), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	alpha := newFakeAlpha()
+	tracker := &Tracker{alpha: alpha, entryListPath: path}
+
+	// The server did not report its maximum number of clients
+	tracker.Reconcile(&types.ServerState{})
+	if alpha.capacity != 2 {
+		t.Errorf("capacity %d, want the 2 slots players can join", alpha.capacity)
+	}
+
+	// Without an entry list, the capacity is left unset
+	alpha = newFakeAlpha()
+	tracker = &Tracker{alpha: alpha, entryListPath: filepath.Join(t.TempDir(), "missing.ini")}
+	tracker.Reconcile(&types.ServerState{})
+	if alpha.capacities != 0 {
+		t.Errorf("capacity set to %d without an entry list", alpha.capacity)
+	}
+}
+
+func TestNilTracker(t *testing.T) {
+	var tracker *Tracker
+	tracker.Connect("76561198000000001")
+	tracker.Disconnect("76561198000000001")
+	tracker.Reconcile(&types.ServerState{})
+}
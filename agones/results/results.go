@@ -0,0 +1,344 @@
+// Package results exports the results of the completed sessions in the Kunos results JSON
+// layout and as CSV, and optionally posts them to a webhook.
+package results
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"agones/metrics"
+	"agones/session"
+	"agones/types"
+	"agones/utils"
+)
+
+// noLap is the lap time Kunos results use for drivers without a valid lap.
+const noLap = 999999999
+
+// Result is a session result in the Kunos results JSON layout.
+type Result struct {
+	TrackName    string        `json:"TrackName"`
+	TrackConfig  string        `json:"TrackConfig"`
+	Type         string        `json:"Type"`
+	DurationSecs int           `json:"DurationSecs"` // Length of a timed session, 0 otherwise
+	RaceLaps     int           `json:"RaceLaps"`     // Length of a session run over laps, 0 otherwise
+	Cars         []Car         `json:"Cars"`
+	Result       []DriverEntry `json:"Result"`
+	Laps         []Lap         `json:"Laps"`
+	Events       []interface{} `json:"Events"`
+}
+
+// Car is a car of the session.
+type Car struct {
+	CarID      int    `json:"CarId"`
+	Driver     Driver `json:"Driver"`
+	Model      string `json:"Model"`
+	Skin       string `json:"Skin"`
+	BallastKG  int    `json:"BallastKG"`
+	Restrictor int    `json:"Restrictor"`
+}
+
+// Driver is the driver of a car.
+type Driver struct {
+	Name      string   `json:"Name"`
+	Team      string   `json:"Team"`
+	Nation    string   `json:"Nation"`
+	GUID      string   `json:"Guid"`
+	GuidsList []string `json:"GuidsList"`
+}
+
+// DriverEntry is the classification of a driver.
+type DriverEntry struct {
+	DriverName string `json:"DriverName"`
+	DriverGUID string `json:"DriverGuid"`
+	CarID      int    `json:"CarId"`
+	CarModel   string `json:"CarModel"`
+	BestLap    int64  `json:"BestLap"`   // Best lap time (ms)
+	TotalTime  int64  `json:"TotalTime"` // Sum of the lap times (ms)
+	BallastKG  int    `json:"BallastKG"`
+	Restrictor int    `json:"Restrictor"`
+}
+
+// Lap is a lap completed during the session.
+type Lap struct {
+	DriverName string  `json:"DriverName"`
+	DriverGUID string  `json:"DriverGuid"`
+	CarID      int     `json:"CarId"`
+	CarModel   string  `json:"CarModel"`
+	Timestamp  int64   `json:"Timestamp"` // Time since the start of the session (ms)
+	LapTime    int64   `json:"LapTime"`   // Lap time (ms)
+	Sectors    []int64 `json:"Sectors"`
+	Cuts       int     `json:"Cuts"`
+	BallastKG  int     `json:"BallastKG"`
+	Tyre       string  `json:"Tyre"`
+	Restrictor int     `json:"Restrictor"`
+}
+
+// kunosSessionTypes maps the session types to the type names of the Kunos results.
+var kunosSessionTypes = map[string]string{
+	types.SessionTypePractice:   "PRACTICE",
+	types.SessionTypeQualifying: "QUALIFY",
+	types.SessionTypeRace:       "RACE",
+}
+
+// Build builds the result of a completed session, classified by the session leaderboard.
+func Build(s *types.Session) Result {
+	sessionType, ok := kunosSessionTypes[s.Type]
+	if !ok {
+		sessionType = strings.ToUpper(s.Type)
+	}
+
+	result := Result{
+		TrackName:   s.Track,
+		TrackConfig: s.Layout,
+		Type:        sessionType,
+		Cars:        []Car{},
+		Result:      []DriverEntry{},
+		Laps:        []Lap{},
+		Events:      []interface{}{},
+	}
+	result.DurationSecs, result.RaceLaps = sessionLength(s.Length)
+
+	// Cars are numbered in the order the drivers joined the session
+	carIDs := make(map[string]int, len(s.Participants))
+	for i, p := range s.Participants {
+		carIDs[p.SteamID] = i
+		result.Cars = append(result.Cars, Car{
+			CarID:  i,
+			Driver: Driver{Name: p.Name, GUID: p.SteamID, GuidsList: []string{p.SteamID}},
+			Model:  p.CarModel,
+		})
+	}
+
+	for _, p := range session.Leaderboard(s) {
+		bestLap := p.BestLap
+		if bestLap == 0 {
+			bestLap = noLap
+		}
+		result.Result = append(result.Result, DriverEntry{
+			DriverName: p.Name,
+			DriverGUID: p.SteamID,
+			CarID:      carIDs[p.SteamID],
+			CarModel:   p.CarModel,
+			BestLap:    bestLap,
+			TotalTime:  p.TotalTime,
+		})
+	}
+
+	for _, lap := range s.Laps {
+		result.Laps = append(result.Laps, Lap{
+			DriverName: lap.Name,
+			DriverGUID: lap.SteamID,
+			CarID:      carIDs[lap.SteamID],
+			CarModel:   lap.CarModel,
+			Timestamp:  lap.Timestamp.Sub(s.StartTime).Milliseconds(),
+			LapTime:    lap.LapTime,
+			Sectors:    []int64{},
+			Cuts:       lap.Cuts,
+		})
+	}
+	return result
+}
+
+// sessionLength converts a session length logged by the server, "15 minutes" or "10 laps",
+// to the duration in seconds and the number of laps. Infinite sessions have neither.
+func sessionLength(length string) (durationSecs, raceLaps int) {
+	fields := strings.Fields(length)
+	if len(fields) != 2 {
+		return 0, 0
+	}
+	n, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, 0
+	}
+	switch fields[1] {
+	case "minutes":
+		return n * 60, 0
+	case "laps":
+		return 0, n
+	}
+	return 0, 0
+}
+
+// WriteCSV writes the classification of a completed session as CSV, one driver per line.
+func WriteCSV(out io.Writer, s *types.Session) error {
+	w := csv.NewWriter(out)
+	if err := w.Write([]string{"Position", "DriverName", "DriverGuid", "CarModel", "Laps", "BestLap", "TotalTime", "Cuts"}); err != nil {
+		return err
+	}
+	for i, p := range session.Leaderboard(s) {
+		bestLap := ""
+		if p.BestLap > 0 {
+			bestLap = strconv.FormatInt(p.BestLap, 10)
+		}
+		err := w.Write([]string{
+			strconv.Itoa(i + 1),
+			p.Name,
+			p.SteamID,
+			p.CarModel,
+			strconv.Itoa(p.Laps),
+			bestLap,
+			strconv.FormatInt(p.TotalTime, 10),
+			strconv.Itoa(p.Cuts),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// Exporter exports the results of the sessions completed by a SessionManager.
+type Exporter struct {
+	dir        string // Directory the results are written to, empty to skip the files
+	webhookURL string // URL the JSON results are posted to, empty to skip the webhook
+	client     *http.Client
+	state      *types.ServerState // Server state the metric labels are read from
+}
+
+// NewExporter creates an Exporter writing to dir and posting to webhookURL.
+func NewExporter(dir, webhookURL string, state *types.ServerState) *Exporter {
+	return &Exporter{
+		dir:        dir,
+		webhookURL: webhookURL,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		state:      state,
+	}
+}
+
+// Run exports the result of every session ended until ctx is cancelled.
+// Exports in progress are not cancelled with ctx, so that the last session of a server
+// shutting down is still exported; the webhook is bounded by the client timeout instead.
+func (e *Exporter) Run(ctx context.Context, sessions *session.SessionManager) {
+	transitions, unsubscribe := sessions.Subscribe(16)
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Export the sessions ended during the shutdown
+			for {
+				select {
+				case transition := <-transitions:
+					e.export(transition.Ended)
+				default:
+					return
+				}
+			}
+		case transition, ok := <-transitions:
+			if !ok {
+				return
+			}
+			e.export(transition.Ended)
+		}
+	}
+}
+
+// export exports the result of an ended session. Sessions nobody took part in are skipped.
+func (e *Exporter) export(s *types.Session) {
+	if s == nil {
+		return
+	}
+	if len(s.Participants) == 0 {
+		utils.LogDebug("Skipping results of the %s session without participants", s.Type)
+		return
+	}
+
+	result := Build(s)
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		utils.LogError("Failed to encode session results: %v", err)
+		return
+	}
+
+	if e.dir != "" {
+		name := fileName(s.EndTime, result.Type)
+		err := e.writeFiles(name, data, s)
+		e.record("file", err)
+		if err != nil {
+			utils.LogError("Failed to write session results: %v", err)
+		} else {
+			utils.LogEvent("RESULTS", "Results of the %s session written to %s", s.Type, filepath.Join(e.dir, name+".json"))
+		}
+	}
+
+	if e.webhookURL != "" {
+		err := e.post(data)
+		e.record("webhook", err)
+		if err != nil {
+			utils.LogError("Failed to post session results: %v", err)
+		}
+	}
+}
+
+// fileName returns the name of the result files of a session ended at end, named like the
+// Kunos results with the seconds added so that sessions ended within a minute do not
+// overwrite each other, e.g. 2024_1_20_19_30_5_RACE.
+func fileName(end time.Time, sessionType string) string {
+	return fmt.Sprintf("%d_%d_%d_%d_%d_%d_%s", end.Year(), end.Month(), end.Day(), end.Hour(), end.Minute(), end.Second(), sessionType)
+}
+
+// writeFiles writes the JSON and CSV results named name to the results directory.
+func (e *Exporter) writeFiles(name string, data []byte, s *types.Session) error {
+	if err := os.MkdirAll(e.dir, 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(e.dir, name+".json"), data, 0644); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, s); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(e.dir, name+".csv"), buf.Bytes(), 0644)
+}
+
+// post posts the JSON results to the webhook.
+func (e *Exporter) post(data []byte) error {
+	req, err := http.NewRequest(http.MethodPost, e.webhookURL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// record counts an export to target.
+func (e *Exporter) record(target string, err error) {
+	e.state.RLock()
+	labels := prometheus.Labels{
+		"server_id":   e.state.ServerID,
+		"server_name": e.state.ServerName,
+		"server_type": e.state.ServerType,
+		"target":      target,
+		"outcome":     "success",
+	}
+	e.state.RUnlock()
+	if err != nil {
+		labels["outcome"] = "failure"
+	}
+	metrics.ResultsExportsCounter.With(labels).Inc()
+}
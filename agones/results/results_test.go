@@ -0,0 +1,95 @@
+package results
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"agones/types"
+)
+
+func TestBuild(t *testing.T) {
+	start := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	s := &types.Session{
+		Type:      types.SessionTypeRace,
+		Track:     "ks_nordschleife",
+		Layout:    "endurance",
+		Length:    "10 laps",
+		StartTime: start,
+		EndTime:   start.Add(20 * time.Minute),
+		Participants: []types.Participant{
+			{SteamID: "76561198000000001", Name: "Bob", CarModel: "ks_mazda_miata", Laps: 1, BestLap: 92456, TotalTime: 92456},
+			{SteamID: "76561198000000002", Name: "Alice", CarModel: "bmw_m3_e30"},
+		},
+		Laps: []types.Lap{
+			{SteamID: "76561198000000001", Name: "Bob", CarModel: "ks_mazda_miata", LapTime: 92456, Timestamp: start.Add(2 * time.Minute)},
+		},
+	}
+
+	result := Build(s)
+	if result.TrackName != "ks_nordschleife" || result.TrackConfig != "endurance" || result.Type != "RACE" {
+		t.Errorf("track %q (%q), type %q", result.TrackName, result.TrackConfig, result.Type)
+	}
+	if result.RaceLaps != 10 || result.DurationSecs != 0 {
+		t.Errorf("RaceLaps = %d, DurationSecs = %d, want 10 laps", result.RaceLaps, result.DurationSecs)
+	}
+	if len(result.Result) != 2 || result.Result[0].DriverName != "Bob" || result.Result[1].BestLap != noLap {
+		t.Errorf("classification %+v", result.Result)
+	}
+	if len(result.Laps) != 1 || result.Laps[0].Timestamp != 120000 || result.Laps[0].CarID != 0 {
+		t.Errorf("laps %+v", result.Laps)
+	}
+
+	// Only the fields of the Kunos layout are exported
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{"TrackName", "TrackConfig", "Type", "DurationSecs", "RaceLaps", "Cars", "Result", "Laps", "Events"} {
+		if _, ok := fields[field]; !ok {
+			t.Errorf("field %s missing", field)
+		}
+		delete(fields, field)
+	}
+	for field := range fields {
+		t.Errorf("unexpected field %s", field)
+	}
+}
+
+func TestSessionLength(t *testing.T) {
+	tests := []struct {
+		length       string
+		durationSecs int
+		raceLaps     int
+	}{
+		{"15 minutes", 900, 0},
+		{"10 laps", 0, 10},
+		{"Infinite", 0, 0},
+		{"", 0, 0},
+		{"ten laps", 0, 0},
+	}
+	for _, tt := range tests {
+		durationSecs, raceLaps := sessionLength(tt.length)
+		if durationSecs != tt.durationSecs || raceLaps != tt.raceLaps {
+			t.Errorf("sessionLength(%q) = %d, %d, want %d, %d", tt.length, durationSecs, raceLaps, tt.durationSecs, tt.raceLaps)
+		}
+	}
+}
+
+func TestFileName(t *testing.T) {
+	end := time.Date(2024, 1, 20, 19, 30, 5, 0, time.UTC)
+	if name := fileName(end, "RACE"); name != "2024_1_20_19_30_5_RACE" {
+		t.Errorf("fileName = %q", name)
+	}
+	if fileName(end, "RACE") == fileName(end.Add(10*time.Second), "RACE") {
+		t.Error("sessions ended within a minute share a file name")
+	}
+	if !strings.HasSuffix(fileName(end, "QUALIFY"), "_QUALIFY") {
+		t.Error("session type missing from the file name")
+	}
+}
@@ -0,0 +1,223 @@
+// Package rules implements a regex-based rule engine used to interpret server output.
+// Each rule pairs a named regular expression with a priority and an action that
+// the caller executes when the rule matches a line of output.
+package rules
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ActionType identifies what should happen when a rule matches.
+type ActionType string
+
+// Supported rule actions.
+const (
+	ActionHandler         ActionType = "handler"          // Invoke a built-in handler by name
+	ActionSetState        ActionType = "set_state"        // Set a field of the server state
+	ActionIncrementMetric ActionType = "increment_metric" // Increment a named Prometheus counter
+	ActionSetAnnotation   ActionType = "set_annotation"   // Set an Agones annotation
+	ActionEmitEvent       ActionType = "emit_event"       // Emit a structured log event
+)
+
+// Rule describes a single output rule.
+// Value is a template that may reference capture groups using the regexp
+// expansion syntax ($1, ${name}).
+type Rule struct {
+	Name     string     `json:"name" yaml:"name"`                             // Unique name of the rule
+	Pattern  string     `json:"pattern" yaml:"pattern"`                       // Regular expression matched against each line
+	Priority int        `json:"priority" yaml:"priority"`                     // Higher priorities are evaluated first
+	Action   ActionType `json:"action" yaml:"action"`                         // Action executed on match
+	Target   string     `json:"target" yaml:"target"`                         // Handler, state field, metric, annotation or event name
+	Value    string     `json:"value,omitempty" yaml:"value,omitempty"`       // Value template for the action
+	Continue bool       `json:"continue,omitempty" yaml:"continue,omitempty"` // Keep evaluating lower priority rules after a match
+	Disabled bool       `json:"disabled,omitempty" yaml:"disabled,omitempty"` // Disable the rule (useful to turn off a built-in)
+
+	re *regexp.Regexp
+}
+
+// File is the on-disk layout of a rules file.
+type File struct {
+	Rules []Rule `json:"rules" yaml:"rules"`
+}
+
+// Match holds the result of a rule matching a line.
+type Match struct {
+	Rule    *Rule             // The rule that matched
+	Line    string            // The line that was matched
+	Groups  map[string]string // Named capture groups
+	indices []int
+}
+
+// Expand expands a value template against the captured groups of the match.
+func (m *Match) Expand(template string) string {
+	return string(m.Rule.re.ExpandString(nil, template, m.Line, m.indices))
+}
+
+// Engine evaluates an ordered set of rules against lines of output.
+type Engine struct {
+	sync.RWMutex
+	rules []*Rule
+}
+
+// NewEngine creates a new Engine from the provided rules.
+func NewEngine(rules []Rule) (*Engine, error) {
+	e := &Engine{}
+	if err := e.Load(rules); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Load compiles and validates the provided rules and atomically replaces the active rule set.
+// Disabled rules are dropped. Rules are ordered by descending priority, preserving
+// declaration order for equal priorities.
+func (e *Engine) Load(rules []Rule) error {
+	compiled := make([]*Rule, 0, len(rules))
+	seen := make(map[string]bool, len(rules))
+
+	for i := range rules {
+		rule := rules[i]
+		if err := validate(&rule); err != nil {
+			return err
+		}
+		if seen[rule.Name] {
+			return fmt.Errorf("duplicate rule name %q", rule.Name)
+		}
+		seen[rule.Name] = true
+
+		if rule.Disabled {
+			continue
+		}
+
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return fmt.Errorf("rule %q: invalid pattern: %v", rule.Name, err)
+		}
+		rule.re = re
+		compiled = append(compiled, &rule)
+	}
+
+	sort.SliceStable(compiled, func(i, j int) bool {
+		return compiled[i].Priority > compiled[j].Priority
+	})
+
+	e.Lock()
+	e.rules = compiled
+	e.Unlock()
+	return nil
+}
+
+// Match returns the matches for a line in priority order.
+// Evaluation stops at the first matching rule unless that rule has Continue set.
+func (e *Engine) Match(line string) []*Match {
+	e.RLock()
+	defer e.RUnlock()
+
+	var matches []*Match
+	for _, rule := range e.rules {
+		indices := rule.re.FindStringSubmatchIndex(line)
+		if indices == nil {
+			continue
+		}
+
+		matches = append(matches, newMatch(rule, line, indices))
+		if !rule.Continue {
+			break
+		}
+	}
+	return matches
+}
+
+// Rules returns a copy of the active rules in evaluation order.
+func (e *Engine) Rules() []Rule {
+	e.RLock()
+	defer e.RUnlock()
+
+	rules := make([]Rule, 0, len(e.rules))
+	for _, rule := range e.rules {
+		rules = append(rules, *rule)
+	}
+	return rules
+}
+
+// LoadFile reads rules from a YAML or JSON file.
+func LoadFile(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules file: %v", err)
+	}
+
+	// JSON is a subset of YAML, so a single decoder handles both formats
+	var file File
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse rules file %s: %v", path, err)
+	}
+	return file.Rules, nil
+}
+
+// Merge overlays overrides on top of base.
+// A rule in overrides replaces the base rule with the same name; other rules are appended.
+func Merge(base, overrides []Rule) []Rule {
+	merged := append([]Rule{}, base...)
+	index := make(map[string]int, len(merged))
+	for i, rule := range merged {
+		index[rule.Name] = i
+	}
+
+	for _, rule := range overrides {
+		if i, ok := index[rule.Name]; ok {
+			merged[i] = rule
+			continue
+		}
+		index[rule.Name] = len(merged)
+		merged = append(merged, rule)
+	}
+	return merged
+}
+
+// validate checks that a rule has all its required fields.
+func validate(rule *Rule) error {
+	if rule.Name == "" {
+		return fmt.Errorf("rule with pattern %q has no name", rule.Pattern)
+	}
+	if rule.Disabled {
+		return nil
+	}
+	if rule.Pattern == "" {
+		return fmt.Errorf("rule %q has no pattern", rule.Name)
+	}
+	if rule.Target == "" {
+		return fmt.Errorf("rule %q has no target", rule.Name)
+	}
+
+	switch rule.Action {
+	case ActionHandler, ActionSetState, ActionIncrementMetric, ActionSetAnnotation, ActionEmitEvent:
+		return nil
+	default:
+		return fmt.Errorf("rule %q has unknown action %q", rule.Name, rule.Action)
+	}
+}
+
+// newMatch builds a Match from the submatch indices returned by the rule's regexp.
+func newMatch(rule *Rule, line string, indices []int) *Match {
+	groups := make(map[string]string)
+	for i, name := range rule.re.SubexpNames() {
+		if name == "" || indices[2*i] < 0 {
+			continue
+		}
+		groups[name] = line[indices[2*i]:indices[2*i+1]]
+	}
+
+	return &Match{
+		Rule:    rule,
+		Line:    line,
+		Groups:  groups,
+		indices: indices,
+	}
+}
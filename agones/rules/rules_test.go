@@ -0,0 +1,134 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestEngineMatch(t *testing.T) {
+	engine, err := NewEngine([]Rule{
+		{Name: "low", Pattern: `connected`, Priority: 1, Action: ActionHandler, Target: "low"},
+		{Name: "high", Pattern: `has connected`, Priority: 10, Action: ActionHandler, Target: "high"},
+		{Name: "chained", Pattern: `Lobby`, Priority: 20, Action: ActionHandler, Target: "chained", Continue: true},
+		{Name: "lobby", Pattern: `Lobby registration successful`, Priority: 5, Action: ActionHandler, Target: "lobby"},
+		{Name: "off", Pattern: `.*`, Priority: 100, Action: ActionHandler, Target: "off", Disabled: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"[12:00:00 INF] Bob (76561198000000001, 0 (ks_mazda_miata-red)) has connected", []string{"high"}},
+		{"[12:00:00 INF] Bob is disconnected", []string{"low"}},
+		{"[12:00:00 INF] Lobby registration successful", []string{"chained", "lobby"}},
+		{"[12:00:00 INF] Registering server to lobby...", nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, m := range engine.Match(tt.line) {
+			got = append(got, m.Rule.Name)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Match(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestMatchGroups(t *testing.T) {
+	engine, err := NewEngine([]Rule{{
+		Name:    "time_left",
+		Pattern: `Remaining time of session: (?P<minutes>\d+)min`,
+		Action:  ActionSetState,
+		Target:  "session_time_left",
+		Value:   "${minutes}",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	matches := engine.Match("[12:00:00 INF] Remaining time of session: 42min")
+	if len(matches) != 1 {
+		t.Fatalf("got %d matches, want 1", len(matches))
+	}
+	if got := matches[0].Groups["minutes"]; got != "42" {
+		t.Errorf("group minutes = %q, want 42", got)
+	}
+	if got := matches[0].Expand(matches[0].Rule.Value); got != "42" {
+		t.Errorf("Expand = %q, want 42", got)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []Rule
+	}{
+		{"no name", []Rule{{Pattern: `x`, Action: ActionHandler, Target: "x"}}},
+		{"no pattern", []Rule{{Name: "a", Action: ActionHandler, Target: "x"}}},
+		{"no target", []Rule{{Name: "a", Pattern: `x`, Action: ActionHandler}}},
+		{"unknown action", []Rule{{Name: "a", Pattern: `x`, Action: "explode", Target: "x"}}},
+		{"invalid pattern", []Rule{{Name: "a", Pattern: `(`, Action: ActionHandler, Target: "x"}}},
+		{"duplicate", []Rule{
+			{Name: "a", Pattern: `x`, Action: ActionHandler, Target: "x"},
+			{Name: "a", Pattern: `y`, Action: ActionHandler, Target: "y"},
+		}},
+	}
+	for _, tt := range tests {
+		if _, err := NewEngine(tt.rules); err == nil {
+			t.Errorf("%s: NewEngine succeeded, want an error", tt.name)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	base := []Rule{
+		{Name: "a", Pattern: `a`, Action: ActionHandler, Target: "a"},
+		{Name: "b", Pattern: `b`, Action: ActionHandler, Target: "b"},
+	}
+	merged := Merge(base, []Rule{
+		{Name: "b", Disabled: true},
+		{Name: "c", Pattern: `c`, Action: ActionEmitEvent, Target: "C"},
+	})
+
+	var names []string
+	for _, rule := range merged {
+		names = append(names, rule.Name)
+	}
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("merged rules = %v, want %v", names, want)
+	}
+	if !merged[1].Disabled {
+		t.Error("override of b was not applied")
+	}
+	if base[1].Disabled {
+		t.Error("Merge modified the base rules")
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	data := `rules:
+  - name: chat
+    pattern: 'CHAT: (?P<message>.*)'
+    action: emit_event
+    target: CHAT
+    value: '${message}'
+`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Rule{{Name: "chat", Pattern: `CHAT: (?P<message>.*)`, Action: ActionEmitEvent, Target: "CHAT", Value: "${message}"}}
+	if !reflect.DeepEqual(loaded, want) {
+		t.Errorf("LoadFile = %+v, want %+v", loaded, want)
+	}
+}
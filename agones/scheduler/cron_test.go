@@ -0,0 +1,58 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleNext(t *testing.T) {
+	ist := time.FixedZone("IST", 5*3600+30*60)
+	// Tuesday
+	from := time.Date(2026, 3, 10, 10, 7, 30, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"*/15 * * * *", from, time.Date(2026, 3, 10, 10, 15, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 3, 10, 10, 45, 0, 0, time.UTC), time.Date(2026, 3, 10, 11, 0, 0, 0, time.UTC)},
+		{"5/10 * * * *", from, time.Date(2026, 3, 10, 10, 15, 0, 0, time.UTC)},
+		{"5/10 * * * *", time.Date(2026, 3, 10, 10, 55, 0, 0, time.UTC), time.Date(2026, 3, 10, 11, 5, 0, 0, time.UTC)},
+		{"0 9 * * 0-7", from, time.Date(2026, 3, 11, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * 7", from, time.Date(2026, 3, 15, 9, 0, 0, 0, time.UTC)},
+		{"30 4 1,15 * 5", from, time.Date(2026, 3, 13, 4, 30, 0, 0, time.UTC)},
+		{"@daily", from, time.Date(2026, 3, 11, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", from, time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", from, time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", from, time.Time{}},
+		{"0 4 * * *", time.Date(2026, 3, 10, 10, 7, 0, 0, ist), time.Date(2026, 3, 11, 4, 0, 0, 0, ist)},
+		{"0 * * * *", time.Date(2026, 3, 10, 10, 7, 0, 0, ist), time.Date(2026, 3, 10, 11, 0, 0, 0, ist)},
+	}
+	for _, tt := range tests {
+		s, err := parseCron(tt.expr)
+		if err != nil {
+			t.Errorf("parseCron(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := s.next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("%q after %v = %v, want %v", tt.expr, tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"@yearly",
+	} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("parseCron(%q) succeeded", expr)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+// Package scheduler runs cron-like jobs on the game server: chat broadcasts, restarts at the
+// end of the session and drains at a wall-clock time.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"agones/metrics"
+	"agones/session"
+	"agones/types"
+	"agones/utils"
+)
+
+// Action is the action of a scheduled job.
+type Action string
+
+// Supported job actions.
+const (
+	ActionBroadcast Action = "broadcast" // Send the job message to every player
+	ActionRestart   Action = "restart"   // Restart the server once the current session ends
+	ActionDrain     Action = "drain"     // Drain the server, then shut it down
+)
+
+// Sources of the jobs.
+const (
+	SourceConfig     = "config"     // Jobs of the schedule configuration key
+	SourceAnnotation = "annotation" // Jobs of the schedule annotation of the GameServer
+)
+
+// Outcomes of the job executions.
+const (
+	outcomeSuccess  = "success"
+	outcomeFailure  = "failure"
+	outcomeDeferred = "deferred" // A restart waits for the end of the session
+)
+
+// errDisabled is returned for the actions that are not available on this server.
+var errDisabled = errors.New("action is disabled")
+
+// Annotation is the GameServer annotation holding the jobs applied on allocation.
+const Annotation = "schedule"
+
+// Job is a scheduled job.
+type Job struct {
+	Spec    string // Cron expression
+	Action  Action
+	Message string // Message of a broadcast job
+
+	schedule schedule
+}
+
+// Parse parses a list of jobs separated by semicolons or line breaks, each made of a cron
+// expression, an action and its argument, e.g. "0 4 * * * drain; */30 * * * * broadcast Hello".
+func Parse(spec string) ([]Job, error) {
+	var jobs []Job
+	for _, entry := range strings.FieldsFunc(spec, func(r rune) bool { return r == ';' || r == '\n' }) {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+
+		// Aliases such as @daily replace the five cron fields
+		n := len(cronFields)
+		if strings.HasPrefix(fields[0], "@") {
+			n = 1
+		}
+		if len(fields) <= n {
+			return nil, fmt.Errorf("job %q has no action", strings.TrimSpace(entry))
+		}
+
+		job := Job{
+			Spec:    strings.Join(fields[:n], " "),
+			Action:  Action(fields[n]),
+			Message: strings.Join(fields[n+1:], " "),
+		}
+		var err error
+		if job.schedule, err = parseCron(job.Spec); err != nil {
+			return nil, err
+		}
+		switch job.Action {
+		case ActionBroadcast:
+			if job.Message == "" {
+				return nil, fmt.Errorf("broadcast job %q has no message", strings.TrimSpace(entry))
+			}
+		case ActionRestart, ActionDrain:
+		default:
+			return nil, fmt.Errorf("unknown job action %q", job.Action)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// Config configures how the jobs are executed. Jobs whose action is nil fail.
+type Config struct {
+	Broadcast func(ctx context.Context, message string) error // Sends a chat message to every player
+	Restart   func() error                                    // Restarts the server process
+	Drain     func(reason string) error                       // Drains the server, then shuts it down
+}
+
+// JobStatus is the status of a job, as exposed by the admin API.
+type JobStatus struct {
+	Spec      string    `json:"spec"`
+	Action    Action    `json:"action"`
+	Message   string    `json:"message,omitempty"`
+	Source    string    `json:"source"`
+	NextRun   time.Time `json:"next_run"`
+	LastRun   time.Time `json:"last_run,omitempty"`
+	LastError string    `json:"last_error,omitempty"`
+	Runs      int       `json:"runs"`
+	Failures  int       `json:"failures"`
+	Pending   bool      `json:"pending,omitempty"` // A restart waits for the end of the session
+}
+
+// entry is a job with its execution status.
+type entry struct {
+	job    Job
+	status JobStatus
+}
+
+// Scheduler executes the jobs.
+type Scheduler struct {
+	state    *types.ServerState
+	sessions *session.SessionManager
+	config   Config
+
+	mu             sync.Mutex
+	entries        []*entry
+	pendingRestart *entry // Restart job waiting for the end of the session, nil if none
+}
+
+// New creates a Scheduler.
+func New(state *types.ServerState, sessions *session.SessionManager, config Config) *Scheduler {
+	return &Scheduler{
+		state:    state,
+		sessions: sessions,
+		config:   config,
+	}
+}
+
+// SetJobs replaces the jobs of a source.
+func (s *Scheduler) SetJobs(source string, jobs []Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := s.entries[:0:0]
+	for _, e := range s.entries {
+		if e.status.Source != source {
+			entries = append(entries, e)
+		}
+	}
+	now := time.Now()
+	for _, job := range jobs {
+		entries = append(entries, &entry{
+			job: job,
+			status: JobStatus{
+				Spec:    job.Spec,
+				Action:  job.Action,
+				Message: job.Message,
+				Source:  source,
+				NextRun: job.schedule.next(now),
+			},
+		})
+	}
+	s.entries = entries
+	if s.pendingRestart != nil && s.pendingRestart.status.Source == source {
+		s.pendingRestart = nil
+	}
+	utils.LogSDK("Scheduled %d jobs from the %s", len(jobs), source)
+}
+
+// Status returns the status of the jobs.
+func (s *Scheduler) Status() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := make([]JobStatus, 0, len(s.entries))
+	for _, e := range s.entries {
+		status = append(status, e.status)
+	}
+	return status
+}
+
+// Run executes the jobs at the start of every matching minute until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	transitions, unsubscribe := s.sessions.Subscribe(4)
+	defer unsubscribe()
+
+	for {
+		now := time.Now()
+		timer := time.NewTimer(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case transition, ok := <-transitions:
+			timer.Stop()
+			if !ok {
+				transitions = nil
+				continue
+			}
+			if transition.Ended != nil {
+				s.restartIfPending()
+			}
+		case tick := <-timer.C:
+			s.runDue(ctx, tick)
+		}
+	}
+}
+
+// runDue executes the jobs matching the minute of now.
+func (s *Scheduler) runDue(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	var due []*entry
+	for _, e := range s.entries {
+		if e.job.schedule.matches(now) {
+			due = append(due, e)
+		}
+		e.status.NextRun = e.job.schedule.next(now)
+	}
+	s.mu.Unlock()
+
+	for _, e := range due {
+		s.execute(ctx, e, now)
+	}
+}
+
+// execute executes a job and records its outcome.
+func (s *Scheduler) execute(ctx context.Context, e *entry, now time.Time) {
+	utils.LogEvent("SCHEDULE", "Running %s job (%s)", e.job.Action, e.job.Spec)
+
+	err := errDisabled
+	switch e.job.Action {
+	case ActionBroadcast:
+		if s.config.Broadcast != nil {
+			err = s.config.Broadcast(ctx, e.job.Message)
+		}
+	case ActionRestart:
+		if s.config.Restart != nil {
+			if s.sessionInProgress() {
+				// The restart waits for the end of the session so that no race is cut short
+				s.mu.Lock()
+				if s.pendingRestart != nil {
+					s.pendingRestart.status.Pending = false
+				}
+				s.pendingRestart = e
+				s.mu.Unlock()
+				utils.LogSDK("Server restart scheduled at the end of the current session")
+				s.recordDeferred(e, now)
+				return
+			}
+			err = s.config.Restart()
+		}
+	case ActionDrain:
+		if s.config.Drain != nil {
+			err = s.config.Drain(fmt.Sprintf("scheduled at %s", e.job.Spec))
+		}
+	}
+	s.record(e, now, err)
+}
+
+// restartIfPending restarts the server if a restart job waits for the end of the session.
+func (s *Scheduler) restartIfPending() {
+	s.mu.Lock()
+	e := s.pendingRestart
+	s.pendingRestart = nil
+	if e != nil {
+		e.status.Pending = false
+	}
+	s.mu.Unlock()
+	if e == nil {
+		return
+	}
+
+	utils.LogEvent("SCHEDULE", "Session ended, restarting the server as scheduled")
+	s.recordOutcome(e, s.config.Restart())
+}
+
+// sessionInProgress returns whether players are taking part in a session.
+func (s *Scheduler) sessionInProgress() bool {
+	if s.sessions.GetCurrentSession() == nil {
+		return false
+	}
+	s.state.RLock()
+	defer s.state.RUnlock()
+	return s.state.Players > 0
+}
+
+// record records a job execution and its outcome.
+func (s *Scheduler) record(e *entry, now time.Time, err error) {
+	s.mu.Lock()
+	e.status.LastRun = now
+	e.status.Runs++
+	s.mu.Unlock()
+	s.recordOutcome(e, err)
+}
+
+// recordDeferred records a restart job execution waiting for the end of the session.
+// Its outcome is recorded once the server restarts.
+func (s *Scheduler) recordDeferred(e *entry, now time.Time) {
+	s.mu.Lock()
+	e.status.LastRun = now
+	e.status.Runs++
+	e.status.Pending = true
+	s.mu.Unlock()
+	s.count(e, outcomeDeferred)
+}
+
+// recordOutcome records the outcome of a job execution.
+func (s *Scheduler) recordOutcome(e *entry, err error) {
+	outcome := outcomeSuccess
+	if err != nil {
+		outcome = outcomeFailure
+		utils.LogError("Scheduled %s job (%s) failed: %v", e.job.Action, e.job.Spec, err)
+	}
+
+	s.mu.Lock()
+	e.status.LastError = ""
+	if err != nil {
+		e.status.Failures++
+		e.status.LastError = err.Error()
+	}
+	s.mu.Unlock()
+	s.count(e, outcome)
+}
+
+// count counts a job execution outcome in the metrics.
+func (s *Scheduler) count(e *entry, outcome string) {
+	s.state.RLock()
+	labels := prometheus.Labels{
+		"server_id":   s.state.ServerID,
+		"server_name": s.state.ServerName,
+		"server_type": s.state.ServerType,
+		"action":      string(e.job.Action),
+		"outcome":     outcome,
+	}
+	s.state.RUnlock()
+	metrics.ScheduledJobsCounter.With(labels).Inc()
+}
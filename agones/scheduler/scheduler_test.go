@@ -0,0 +1,57 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"agones/session"
+	"agones/types"
+)
+
+func TestRestartJob(t *testing.T) {
+	tests := []struct {
+		name        string
+		session     bool
+		players     int
+		wantPending bool
+	}{
+		{"no session", false, 3, false},
+		{"empty session", true, 0, false},
+		{"session with players", true, 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := &types.ServerState{Players: tt.players}
+			sessions := session.NewSessionManager(10)
+			if tt.session {
+				sessions.StartNewSession(types.SessionTypeRace, "monza")
+			}
+			restarts := 0
+			s := New(state, sessions, Config{Restart: func() error {
+				restarts++
+				return nil
+			}})
+			jobs, err := Parse("0 4 * * * restart")
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.SetJobs(SourceConfig, jobs)
+
+			s.execute(context.Background(), s.entries[0], time.Now())
+			status := s.Status()[0]
+			if status.Runs != 1 || status.Pending != tt.wantPending {
+				t.Errorf("after the job: Runs = %d, Pending = %v, want 1, %v", status.Runs, status.Pending, tt.wantPending)
+			}
+			if want := map[bool]int{true: 0, false: 1}[tt.wantPending]; restarts != want {
+				t.Errorf("after the job: %d restarts, want %d", restarts, want)
+			}
+
+			s.restartIfPending()
+			status = s.Status()[0]
+			if restarts != 1 || status.Runs != 1 || status.Pending {
+				t.Errorf("after the session: %d restarts, Runs = %d, Pending = %v, want 1, 1, false", restarts, status.Runs, status.Pending)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+// Package serverconfig renders the AssettoServer configuration from templates filled with
+// the allocation metadata of the GameServer.
+package serverconfig
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// TemplateExt is the extension of the files rendered as templates. Other files are copied as is.
+const TemplateExt = ".tmpl"
+
+// Data is the data passed to the templates.
+type Data struct {
+	Labels      map[string]string // Labels of the GameServer
+	Annotations map[string]string // Annotations of the GameServer
+
+	unsafe map[string]bool // Keys whose value was dropped for containing control characters
+}
+
+// Get returns the annotation with the given key, or the label if there is no such annotation.
+// Values containing control characters, which could add lines to the rendered files, are an error.
+func (d Data) Get(key string) (string, error) {
+	if value, ok := d.Annotations[key]; ok {
+		return value, nil
+	}
+	if d.unsafe[key] {
+		return "", fmt.Errorf("value of %s contains control characters", key)
+	}
+	return d.Labels[key], nil
+}
+
+// safe returns a copy of d without the values containing control characters.
+func (d Data) safe() Data {
+	safe := Data{unsafe: make(map[string]bool)}
+	safe.Labels = safe.filter(d.Labels)
+	safe.Annotations = safe.filter(d.Annotations)
+	return safe
+}
+
+// filter returns a copy of values without the ones containing control characters.
+func (d Data) filter(values map[string]string) map[string]string {
+	filtered := make(map[string]string, len(values))
+	for key, value := range values {
+		if strings.IndexFunc(value, unicode.IsControl) >= 0 {
+			d.unsafe[key] = true
+			continue
+		}
+		filtered[key] = value
+	}
+	return filtered
+}
+
+// funcs are the functions available in the templates, e.g. {{ .Get "track" | default "ks_vallelunga" }}.
+var funcs = template.FuncMap{
+	"default": func(def, value string) string {
+		if value == "" {
+			return def
+		}
+		return value
+	},
+	"split": func(sep, value string) []string {
+		var parts []string
+		for _, part := range strings.Split(value, sep) {
+			if part = strings.TrimSpace(part); part != "" {
+				parts = append(parts, part)
+			}
+		}
+		return parts
+	},
+	"atoi": func(value string) (int, error) {
+		return strconv.Atoi(strings.TrimSpace(value))
+	},
+	"add": func(a, b int) int {
+		return a + b
+	},
+	// yaml quotes a value as a YAML double-quoted scalar, e.g. track: {{ .Get "track" | yaml }}
+	"yaml": func(value string) string {
+		return strconv.Quote(value)
+	},
+}
+
+// Render renders the templates of templateDir into outputDir, keeping the directory layout
+// and stripping the template extension (e.g. cfg/server_cfg.ini.tmpl becomes cfg/server_cfg.ini).
+// The previous content of outputDir is replaced only if every template renders, and Render
+// returns whether it changed.
+func Render(templateDir, outputDir string, data Data) (bool, error) {
+	data = data.safe()
+
+	// Render next to outputDir so that the final rename stays on the same filesystem
+	if err := os.MkdirAll(filepath.Dir(outputDir), 0755); err != nil {
+		return false, err
+	}
+	staging, err := os.MkdirTemp(filepath.Dir(outputDir), ".render-")
+	if err != nil {
+		return false, fmt.Errorf("failed to create staging directory: %v", err)
+	}
+	defer os.RemoveAll(staging)
+	if err := os.Chmod(staging, 0755); err != nil {
+		return false, err
+	}
+
+	err = filepath.WalkDir(templateDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(templateDir, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(staging, strings.TrimSuffix(rel, TemplateExt))
+
+		if entry.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if strings.HasSuffix(path, TemplateExt) {
+			return renderFile(path, target, data)
+		}
+		return copyFile(path, target)
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to render %s: %v", templateDir, err)
+	}
+
+	if sameFiles(staging, outputDir) {
+		return false, nil
+	}
+	if err := os.RemoveAll(outputDir); err != nil {
+		return false, err
+	}
+	return true, os.Rename(staging, outputDir)
+}
+
+// sameFiles returns whether the directories a and b hold the same files with the same content.
+func sameFiles(a, b string) bool {
+	files := func(dir string) map[string]string {
+		contents := make(map[string]string)
+		err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return err
+			}
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(dir, path)
+			contents[rel] = string(content)
+			return err
+		})
+		if err != nil {
+			return nil
+		}
+		return contents
+	}
+
+	filesA, filesB := files(a), files(b)
+	return filesA != nil && filesB != nil && reflect.DeepEqual(filesA, filesB)
+}
+
+// renderFile executes the template at path into target.
+func renderFile(path, target string, data Data) error {
+	tmpl, err := template.New(filepath.Base(path)).Funcs(funcs).Option("missingkey=error").ParseFiles(path)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := tmpl.Execute(file, data); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// copyFile copies the file at path to target.
+func copyFile(path, target string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(target, content, 0644)
+}
@@ -0,0 +1,78 @@
+package serverconfig
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTemplate writes a template named name into a new template directory.
+func writeTemplate(t *testing.T, name, content string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestRender(t *testing.T) {
+	templates := writeTemplate(t, "extra_cfg.yml.tmpl", `Track: {{ .Get "track" | default "ks_vallelunga" | yaml }}
+MaxClients: {{ .Get "slots" | atoi }}
+`)
+	output := filepath.Join(t.TempDir(), "rendered")
+	data := Data{
+		Labels:      map[string]string{"slots": "12"},
+		Annotations: map[string]string{"track": `spa: "x" # y`},
+	}
+
+	changed, err := Render(templates, output, data)
+	if err != nil || !changed {
+		t.Fatalf("Render = %v, %v, want a change", changed, err)
+	}
+	content, err := os.ReadFile(filepath.Join(output, "extra_cfg.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Track: \"spa: \\\"x\\\" # y\"\nMaxClients: 12\n"
+	if string(content) != want {
+		t.Errorf("rendered %q, want %q", content, want)
+	}
+
+	if changed, err := Render(templates, output, data); err != nil || changed {
+		t.Errorf("second Render = %v, %v, want no change", changed, err)
+	}
+	data.Annotations["track"] = "monza"
+	if changed, err := Render(templates, output, data); err != nil || !changed {
+		t.Errorf("Render of new data = %v, %v, want a change", changed, err)
+	}
+}
+
+func TestRenderControlCharacters(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+	}{
+		{"get", `NAME={{ .Get "name" }}`},
+		{"annotation", `NAME={{ .Annotations.name }}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			templates := writeTemplate(t, "server_cfg.ini.tmpl", "[SERVER]\n"+tt.template+"\n")
+			output := filepath.Join(t.TempDir(), "rendered")
+			data := Data{Annotations: map[string]string{"name": "Race\nADMIN_PASSWORD=x"}}
+
+			_, err := Render(templates, output, data)
+			if err == nil {
+				t.Fatal("Render succeeded with a newline in a value")
+			}
+			if tt.name == "get" && !strings.Contains(err.Error(), "control characters") {
+				t.Errorf("Render = %v, want a control characters error", err)
+			}
+			if _, err := os.Stat(output); !os.IsNotExist(err) {
+				t.Errorf("output written despite the failure: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package session
+
+import (
+	"sort"
+
+	"agones/types"
+)
+
+// RecordLap records a completed lap in the current session and updates the participant
+// who drove it. It returns the leaderboard position of the participant (1-based), or 0 if
+// there is no current session.
+func (sm *SessionManager) RecordLap(lap types.Lap) int {
+	sm.Lock()
+	defer sm.Unlock()
+
+	if sm.current == nil {
+		return 0
+	}
+
+	session := sm.current
+	session.Laps = append(session.Laps, lap)
+
+	p := session.Participant(lap.SteamID)
+	if p == nil {
+		session.Participants = append(session.Participants, types.Participant{
+			SteamID:  lap.SteamID,
+			Name:     lap.Name,
+			CarModel: lap.CarModel,
+		})
+		p = &session.Participants[len(session.Participants)-1]
+	}
+	p.Laps++
+	p.LastLap = lap.LapTime
+	p.TotalTime += lap.LapTime
+	p.Cuts += lap.Cuts
+	// Laps with cuts are not valid for the best lap
+	if lap.Cuts == 0 && (p.BestLap == 0 || lap.LapTime < p.BestLap) {
+		p.BestLap = lap.LapTime
+	}
+
+	position := 0
+	for i, ranked := range Leaderboard(session) {
+		if ranked.SteamID == lap.SteamID {
+			position = i + 1
+			break
+		}
+	}
+
+	sm.mirror()
+	return position
+}
+
+// Leaderboard returns the live leaderboard of the current session, empty if there is none.
+func (sm *SessionManager) Leaderboard() []types.Participant {
+	sm.RLock()
+	defer sm.RUnlock()
+	if sm.current == nil {
+		return nil
+	}
+	return Leaderboard(sm.current)
+}
+
+// Leaderboard ranks the participants of a session. Races rank by laps completed, then by
+// total time. Other sessions rank by best lap, participants without a valid lap last.
+func Leaderboard(session *types.Session) []types.Participant {
+	ranked := append([]types.Participant(nil), session.Participants...)
+	race := session.Type == types.SessionTypeRace
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if race {
+			if a.Laps != b.Laps {
+				return a.Laps > b.Laps
+			}
+			return a.TotalTime < b.TotalTime
+		}
+		switch {
+		case a.BestLap == 0:
+			return false
+		case b.BestLap == 0:
+			return true
+		default:
+			return a.BestLap < b.BestLap
+		}
+	})
+	return ranked
+}
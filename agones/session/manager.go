@@ -2,26 +2,35 @@
 package session
 
 import (
-	"agones/types"
 	"sync"
 	"time"
+
+	"agones/types"
+	"agones/utils"
 )
 
-// SessionManager manages the lifecycle of sessions.
+// SessionManager manages the lifecycle of sessions. It is the single source of truth for
+// sessions: the mirrored server state is only written by the manager.
 type SessionManager struct {
 	sync.RWMutex
-	current     *types.Session         // The current active session
-	history     []*types.Session       // History of previous sessions
-	maxHistory  int                    // Maximum number of sessions to keep in history
-	transitions chan SessionTransition // Channel to handle session transitions
-
+	current     *types.Session                 // The current active session
+	history     []*types.Session               // History of previous sessions
+	maxHistory  int                            // Maximum number of sessions to keep in history
+	subscribers map[int]chan SessionTransition // Subscribers to the session transitions
+	nextID      int                            // Identifier of the next subscriber
+	completed   int                            // Number of sessions completed since the start
+	firstStart  time.Time                      // Start time of the first session
+	state       *types.ServerState             // Server state mirroring the current session, may be nil
+	store       Store                          // Store of the completed sessions, may be nil
 }
 
 // SessionTransition represents a transition between sessions.
 type SessionTransition struct {
-	From string    // The previous session type
-	To   string    // The new session type
-	Time time.Time // The time the transition occurred
+	From    string         // The previous session type, "none" if there was no session
+	To      string         // The new session type, "none" if no session follows
+	Time    time.Time      // The time the transition occurred
+	Ended   *types.Session // The session that ended, nil if there was no session
+	Started *types.Session // The session that started, nil if no session follows
 }
 
 // NewSessionManager creates a new SessionManager with a specified maximum history size.
@@ -29,61 +38,244 @@ func NewSessionManager(maxHistory int) *SessionManager {
 	return &SessionManager{
 		history:     make([]*types.Session, 0, maxHistory),
 		maxHistory:  maxHistory,
-		transitions: make(chan SessionTransition, 50),
+		subscribers: make(map[int]chan SessionTransition),
+	}
+}
+
+// Mirror keeps CurrentSession, SessionType and SessionStart of state in sync with the manager.
+func (sm *SessionManager) Mirror(state *types.ServerState) {
+	sm.Lock()
+	defer sm.Unlock()
+	sm.state = state
+	if sm.current != nil {
+		sm.mirror()
 	}
 }
 
-// StartNewSession initiates a new session of the given type.
+// SetStore persists the completed sessions to store and loads the history from it.
+func (sm *SessionManager) SetStore(store Store) error {
+	history, err := store.Load(sm.maxHistory)
+	if err != nil {
+		return err
+	}
+
+	sm.Lock()
+	defer sm.Unlock()
+	sm.store = store
+	sm.history = append(history, sm.history...)
+	if len(sm.history) > sm.maxHistory {
+		sm.history = sm.history[len(sm.history)-sm.maxHistory:]
+	}
+	return nil
+}
+
+// StartNewSession initiates a new session of the given type on the given track.
 // It archives the current session if one exists and records the transition.
-func (sm *SessionManager) StartNewSession(sessionType string) error {
+func (sm *SessionManager) StartNewSession(sessionType, track string) error {
+	return sm.StartSession(types.Session{Type: sessionType, Track: track})
+}
+
+// StartSession initiates a new session described by next, starting now.
+// It archives the current session if one exists and records the transition.
+func (sm *SessionManager) StartSession(next types.Session) error {
+	sm.persist(sm.startSession(next))
+	return nil
+}
+
+// startSession starts a new session and returns the session it ended, if any.
+func (sm *SessionManager) startSession(next types.Session) *types.Session {
 	sm.Lock()
 	defer sm.Unlock()
 
+	now := time.Now()
+	transition := SessionTransition{From: "none", To: next.Type, Time: now}
 	if sm.current != nil {
-		sm.archiveCurrentSession()
+		transition.From = sm.current.Type
+		transition.Ended = sm.archiveCurrentSession()
 	}
 
 	sm.current = &types.Session{
-		Type:      sessionType,
-		StartTime: time.Now(),
+		Type:      next.Type,
+		StartTime: now,
+		Track:     next.Track,
+		Layout:    next.Layout,
+		Length:    next.Length,
+	}
+	if sm.firstStart.IsZero() {
+		sm.firstStart = now
 	}
+	transition.Started = sm.current.Clone()
+
+	sm.mirror()
+	sm.publish(transition)
+	return transition.Ended
+}
 
-	sm.transitions <- SessionTransition{
-		From: "none",
-		To:   sessionType,
-		Time: time.Now(),
+// EndCurrentSession archives the current session, if any, without starting a new one.
+// It returns false if there was no current session.
+func (sm *SessionManager) EndCurrentSession() bool {
+	ended := sm.endCurrentSession()
+	if ended == nil {
+		return false
 	}
+	sm.persist(ended)
+	return true
+}
 
-	return nil
+// endCurrentSession ends the current session and returns it, nil if there was none.
+func (sm *SessionManager) endCurrentSession() *types.Session {
+	sm.Lock()
+	defer sm.Unlock()
+
+	if sm.current == nil {
+		return nil
+	}
+	transition := SessionTransition{From: sm.current.Type, To: "none", Time: time.Now()}
+	transition.Ended = sm.archiveCurrentSession()
+	sm.current = nil
+
+	sm.mirror()
+	sm.publish(transition)
+	return transition.Ended
 }
 
-// archiveCurrentSession archives the current session to the history.
+// AddParticipant records a player as a participant of the current session, if any.
+// A participant already recorded keeps its laps.
+func (sm *SessionManager) AddParticipant(player types.Player) {
+	sm.UpdateCurrentSession(func(session *types.Session) {
+		if p := session.Participant(player.SteamID); p != nil {
+			p.Name = player.Name
+			p.CarModel = player.CarModel
+			return
+		}
+		session.Participants = append(session.Participants, types.Participant{
+			SteamID:  player.SteamID,
+			Name:     player.Name,
+			CarModel: player.CarModel,
+		})
+	})
+}
+
+// UpdateCurrentSession applies update to the current session, if any.
+func (sm *SessionManager) UpdateCurrentSession(update func(session *types.Session)) {
+	sm.Lock()
+	defer sm.Unlock()
+
+	if sm.current == nil {
+		return
+	}
+	update(sm.current)
+	sm.mirror()
+}
+
+// archiveCurrentSession archives the current session to the history and returns a copy of it.
 // If the history exceeds maxHistory, it removes the oldest session.
-func (sm *SessionManager) archiveCurrentSession() {
+func (sm *SessionManager) archiveCurrentSession() *types.Session {
 	if len(sm.history) >= sm.maxHistory {
 		// Remove the oldest session
 		sm.history = sm.history[1:]
 	}
 	sm.current.EndTime = time.Now()
 	sm.history = append(sm.history, sm.current)
+	sm.completed++
+	return sm.current.Clone()
+}
+
+// persist saves a completed session to the store, if any. It is called without the lock held
+// so that a slow store does not block the output processing.
+func (sm *SessionManager) persist(session *types.Session) {
+	sm.RLock()
+	store := sm.store
+	sm.RUnlock()
+	if store == nil || session == nil {
+		return
+	}
+
+	if err := store.Save(session); err != nil {
+		utils.LogWarning("Failed to persist session: %v", err)
+	}
+}
+
+// mirror copies the current session to the mirrored state. Must be called with the lock held.
+func (sm *SessionManager) mirror() {
+	if sm.state == nil {
+		return
+	}
+
+	sm.state.Lock()
+	defer sm.state.Unlock()
+	if sm.current == nil {
+		sm.state.CurrentSession = nil
+		return
+	}
+	current := sm.current.Clone()
+	sm.state.CurrentSession = current
+	sm.state.SessionType = current.Type
+	sm.state.SessionStart = current.StartTime
 }
 
-// GetCurrentSession returns the current active session.
+// Subscribe registers a subscriber to the session transitions, buffered with the given size.
+// Transitions are dropped for a subscriber whose buffer is full, so that a slow subscriber
+// never blocks the output processing. The returned function unsubscribes.
+func (sm *SessionManager) Subscribe(buffer int) (<-chan SessionTransition, func()) {
+	sm.Lock()
+	defer sm.Unlock()
+
+	id := sm.nextID
+	sm.nextID++
+	ch := make(chan SessionTransition, buffer)
+	sm.subscribers[id] = ch
+
+	return ch, func() {
+		sm.Lock()
+		defer sm.Unlock()
+		if ch, ok := sm.subscribers[id]; ok {
+			delete(sm.subscribers, id)
+			close(ch)
+		}
+	}
+}
+
+// publish sends a transition to the subscribers. Must be called with the lock held.
+func (sm *SessionManager) publish(transition SessionTransition) {
+	for id, ch := range sm.subscribers {
+		select {
+		case ch <- transition:
+		default:
+			utils.LogWarning("Session transition %s -> %s dropped for subscriber %d", transition.From, transition.To, id)
+		}
+	}
+}
+
+// GetCurrentSession returns a copy of the current active session, nil if there is none.
 func (sm *SessionManager) GetCurrentSession() *types.Session {
 	sm.RLock()
 	defer sm.RUnlock()
-	return sm.current
+	if sm.current == nil {
+		return nil
+	}
+	return sm.current.Clone()
 }
 
-// GetSessionHistory returns a defensive copy of the session history.
+// GetSessionHistory returns a copy of the session history, oldest first.
 func (sm *SessionManager) GetSessionHistory() []*types.Session {
 	sm.RLock()
 	defer sm.RUnlock()
-	return append([]*types.Session{}, sm.history...)
+
+	history := make([]*types.Session, len(sm.history))
+	for i, session := range sm.history {
+		history[i] = session.Clone()
+	}
+	return history
 }
 
-// Close closes the session transitions channel.
+// Close closes the channels of all subscribers.
 func (sm *SessionManager) Close() error {
-	close(sm.transitions)
+	sm.Lock()
+	defer sm.Unlock()
+	for id, ch := range sm.subscribers {
+		delete(sm.subscribers, id)
+		close(ch)
+	}
 	return nil
 }
@@ -0,0 +1,124 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"agones/types"
+)
+
+// blockingStore is a Store whose saves wait until released.
+type blockingStore struct {
+	saving  chan *types.Session
+	release chan struct{}
+}
+
+func (s *blockingStore) Save(session *types.Session) error {
+	s.saving <- session
+	<-s.release
+	return nil
+}
+
+func (s *blockingStore) Load(int) ([]*types.Session, error) {
+	return nil, nil
+}
+
+func TestGetSessionHistoryCopies(t *testing.T) {
+	sm := NewSessionManager(10)
+	sm.StartNewSession(types.SessionTypePractice, "monza")
+	sm.AddParticipant(types.Player{SteamID: "76561198000000001", Name: "Bob"})
+	sm.EndCurrentSession()
+
+	history := sm.GetSessionHistory()
+	history[0].Track = "spa"
+	history[0].Participants[0].Name = "Alice"
+
+	session := sm.GetSessionHistory()[0]
+	if session.Track != "monza" || session.Participants[0].Name != "Bob" {
+		t.Errorf("history changed through a returned session: %+v", session)
+	}
+}
+
+func TestStoreSaveOutsideLock(t *testing.T) {
+	store := &blockingStore{saving: make(chan *types.Session), release: make(chan struct{})}
+	sm := NewSessionManager(10)
+	if err := sm.SetStore(store); err != nil {
+		t.Fatal(err)
+	}
+	sm.StartNewSession(types.SessionTypePractice, "monza")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sm.StartNewSession(types.SessionTypeQualifying, "monza")
+	}()
+
+	saved := <-store.saving
+	if saved.Type != types.SessionTypePractice {
+		t.Errorf("saved a %s session, want the practice", saved.Type)
+	}
+	// The manager stays usable while the store is slow
+	if current := sm.GetCurrentSession(); current == nil || current.Type != types.SessionTypeQualifying {
+		t.Errorf("current session = %+v while saving, want the qualifying", current)
+	}
+	close(store.release)
+	<-done
+}
+
+func TestPublishNeverBlocks(t *testing.T) {
+	sm := NewSessionManager(10)
+	slow, unsubscribe := sm.Subscribe(1) // Never read during the transitions
+	defer unsubscribe()
+	fast, unsubscribeFast := sm.Subscribe(10)
+	defer unsubscribeFast()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sm.StartNewSession(types.SessionTypePractice, "monza")
+		sm.StartNewSession(types.SessionTypeQualifying, "monza")
+		sm.StartNewSession(types.SessionTypeRace, "monza")
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("transitions blocked on a slow subscriber")
+	}
+
+	if got := len(slow); got != 1 {
+		t.Errorf("slow subscriber received %d transitions, want its buffer of 1", got)
+	}
+	if first := <-slow; first.From != "none" || first.To != types.SessionTypePractice {
+		t.Errorf("slow subscriber received %s -> %s, want the first transition", first.From, first.To)
+	}
+	if got := len(fast); got != 3 {
+		t.Errorf("subscriber received %d transitions, want 3", got)
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	sm := NewSessionManager(10)
+	transitions, unsubscribe := sm.Subscribe(1)
+	unsubscribe()
+	if _, ok := <-transitions; ok {
+		t.Error("channel open after unsubscribe")
+	}
+	// Neither unsubscribing again nor publishing panics on the closed channel
+	unsubscribe()
+	sm.StartNewSession(types.SessionTypePractice, "monza")
+}
+
+func TestClose(t *testing.T) {
+	sm := NewSessionManager(10)
+	transitions, unsubscribe := sm.Subscribe(1)
+	if err := sm.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, ok := <-transitions; ok {
+		t.Error("channel open after Close")
+	}
+	if err := sm.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+	unsubscribe()
+}
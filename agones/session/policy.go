@@ -0,0 +1,73 @@
+package session
+
+import (
+	"fmt"
+	"time"
+
+	"agones/types"
+)
+
+// EndPolicy defines what happens when a session ends.
+type EndPolicy string
+
+// Supported end-of-session policies.
+const (
+	EndPolicyShutdown       EndPolicy = "shutdown"                  // Shut down when the server reports the end of a session
+	EndPolicyContinue       EndPolicy = "continue"                  // Keep the server running
+	EndPolicyAfterRace      EndPolicy = "shutdown-after-race"       // Shut down at the end of a race session
+	EndPolicyAfterSessions  EndPolicy = "shutdown-after-n-sessions" // Shut down once a number of sessions completed
+	EndPolicyAfterWallClock EndPolicy = "shutdown-after-wall-clock" // Shut down at the first session end after a duration
+)
+
+// ParseEndPolicy validates an end-of-session policy name.
+func ParseEndPolicy(name string) (EndPolicy, error) {
+	switch p := EndPolicy(name); p {
+	case EndPolicyShutdown, EndPolicyContinue, EndPolicyAfterRace, EndPolicyAfterSessions, EndPolicyAfterWallClock:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown session end policy %q", name)
+	}
+}
+
+// EndConfig configures the end-of-session policy.
+type EndConfig struct {
+	Policy    EndPolicy
+	Sessions  int           // Number of completed sessions for shutdown-after-n-sessions
+	WallClock time.Duration // Time since the first session start for shutdown-after-wall-clock
+}
+
+// Transitions reports whether the policy applies to sessions ended by a transition to the next
+// session, and not only to the end of session reported by the server.
+func (c EndConfig) Transitions() bool {
+	return c.Policy != EndPolicyShutdown && c.Policy != EndPolicyContinue
+}
+
+// ShouldShutdown evaluates the policy against the session history after a session ended.
+// It returns whether the server should shut down and the reason.
+func (sm *SessionManager) ShouldShutdown(config EndConfig) (bool, string) {
+	sm.RLock()
+	defer sm.RUnlock()
+
+	var ended *types.Session
+	if len(sm.history) > 0 {
+		ended = sm.history[len(sm.history)-1]
+	}
+
+	switch config.Policy {
+	case EndPolicyShutdown:
+		return true, "session ended"
+	case EndPolicyAfterRace:
+		if ended != nil && ended.Type == types.SessionTypeRace {
+			return true, "race session ended"
+		}
+	case EndPolicyAfterSessions:
+		if sm.completed >= config.Sessions {
+			return true, fmt.Sprintf("%d sessions completed", sm.completed)
+		}
+	case EndPolicyAfterWallClock:
+		if !sm.firstStart.IsZero() && time.Since(sm.firstStart) >= config.WallClock {
+			return true, fmt.Sprintf("running for more than %v", config.WallClock)
+		}
+	}
+	return false, ""
+}
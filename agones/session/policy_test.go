@@ -0,0 +1,68 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"agones/types"
+)
+
+func TestShouldShutdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   EndConfig
+		sessions []string      // Types of the completed sessions, oldest first
+		running  time.Duration // Time since the first session start
+		want     bool
+	}{
+		{"shutdown", EndConfig{Policy: EndPolicyShutdown}, nil, 0, true},
+		{"continue", EndConfig{Policy: EndPolicyContinue}, []string{types.SessionTypeRace}, 0, false},
+		{"after race, practice ended", EndConfig{Policy: EndPolicyAfterRace},
+			[]string{types.SessionTypeRace, types.SessionTypePractice}, 0, false},
+		{"after race, race ended", EndConfig{Policy: EndPolicyAfterRace},
+			[]string{types.SessionTypePractice, types.SessionTypeQualifying, types.SessionTypeRace}, 0, true},
+		{"after race, no session", EndConfig{Policy: EndPolicyAfterRace}, nil, 0, false},
+		{"after 3 sessions, 2 completed", EndConfig{Policy: EndPolicyAfterSessions, Sessions: 3},
+			[]string{types.SessionTypePractice, types.SessionTypeRace}, 0, false},
+		{"after 3 sessions, 3 completed", EndConfig{Policy: EndPolicyAfterSessions, Sessions: 3},
+			[]string{types.SessionTypePractice, types.SessionTypeQualifying, types.SessionTypeRace}, 0, true},
+		{"after wall clock, before the limit", EndConfig{Policy: EndPolicyAfterWallClock, WallClock: time.Hour},
+			[]string{types.SessionTypePractice}, 59 * time.Minute, false},
+		{"after wall clock, after the limit", EndConfig{Policy: EndPolicyAfterWallClock, WallClock: time.Hour},
+			[]string{types.SessionTypePractice}, 61 * time.Minute, true},
+		{"after wall clock, no session", EndConfig{Policy: EndPolicyAfterWallClock, WallClock: time.Hour}, nil, 0, false},
+	}
+	for _, tt := range tests {
+		sm := NewSessionManager(10)
+		for _, sessionType := range tt.sessions {
+			sm.StartNewSession(sessionType, "monza")
+		}
+		sm.EndCurrentSession()
+		if !sm.firstStart.IsZero() {
+			sm.firstStart = time.Now().Add(-tt.running)
+		}
+
+		got, reason := sm.ShouldShutdown(tt.config)
+		if got != tt.want {
+			t.Errorf("%s: ShouldShutdown = %v (%q), want %v", tt.name, got, reason, tt.want)
+		}
+		if got && reason == "" {
+			t.Errorf("%s: no shutdown reason", tt.name)
+		}
+	}
+}
+
+func TestTransitions(t *testing.T) {
+	tests := map[EndPolicy]bool{
+		EndPolicyShutdown:       false,
+		EndPolicyContinue:       false,
+		EndPolicyAfterRace:      true,
+		EndPolicyAfterSessions:  true,
+		EndPolicyAfterWallClock: true,
+	}
+	for policy, want := range tests {
+		if got := (EndConfig{Policy: policy}).Transitions(); got != want {
+			t.Errorf("%s: Transitions() = %v, want %v", policy, got, want)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package session
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"agones/types"
+)
+
+// Store persists the completed sessions.
+type Store interface {
+	// Save appends a completed session.
+	Save(session *types.Session) error
+	// Load returns up to limit of the most recent sessions, oldest first.
+	Load(limit int) ([]*types.Session, error)
+}
+
+// maxStoreSize is the size of the store file after which it is rotated.
+const maxStoreSize = 16 << 20
+
+// FileStore stores the sessions in a JSON-lines file, one session per line. Once the file
+// exceeds its maximum size, it is rotated to path.1, replacing the previous rotated file.
+type FileStore struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+}
+
+// NewFileStore creates a FileStore writing to path. The file is created on the first save.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path, maxSize: maxStoreSize}
+}
+
+// Save appends a session to the file.
+func (fs *FileStore) Save(session *types.Session) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(fs.path), 0755); err != nil {
+		return err
+	}
+	if info, err := os.Stat(fs.path); err == nil && info.Size()+int64(len(data)) >= fs.maxSize {
+		if err := os.Rename(fs.path, fs.rotatedPath()); err != nil {
+			return fmt.Errorf("failed to rotate %s: %v", fs.path, err)
+		}
+	}
+	file, err := os.OpenFile(fs.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Load reads the most recent sessions of the rotated file and the file. A missing file holds
+// no sessions. Lines that cannot be decoded, such as a line truncated by a crash, are skipped.
+func (fs *FileStore) Load(limit int) ([]*types.Session, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	var sessions []*types.Session
+	for _, path := range []string{fs.rotatedPath(), fs.path} {
+		var err error
+		if sessions, err = load(path, limit, sessions); err != nil {
+			return nil, err
+		}
+	}
+	return sessions, nil
+}
+
+// rotatedPath returns the path of the rotated file.
+func (fs *FileStore) rotatedPath() string {
+	return fs.path + ".1"
+}
+
+// load appends the sessions of the file at path to sessions, keeping up to limit of the most recent.
+func load(path string, limit int, sessions []*types.Session) ([]*types.Session, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return sessions, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var session types.Session
+		if err := json.Unmarshal(scanner.Bytes(), &session); err != nil {
+			continue
+		}
+		sessions = append(sessions, &session)
+		if limit > 0 && len(sessions) > limit {
+			sessions = sessions[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return sessions, nil
+}
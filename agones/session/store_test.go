@@ -0,0 +1,51 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"agones/types"
+)
+
+func TestFileStoreRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions", "history.jsonl")
+	store := NewFileStore(path)
+	store.maxSize = 300
+
+	tracks := []string{"monza", "spa", "imola", "mugello", "vallelunga", "silverstone"}
+	for _, track := range tracks {
+		if err := store.Save(&types.Session{Type: types.SessionTypeRace, Track: track}); err != nil {
+			t.Fatalf("Save %s: %v", track, err)
+		}
+	}
+
+	for _, file := range []string{path, path + ".1"} {
+		info, err := os.Stat(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() > store.maxSize {
+			t.Errorf("%s is %d bytes, over the %d bytes limit", file, info.Size(), store.maxSize)
+		}
+	}
+
+	sessions, err := store.Load(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded []string
+	for _, session := range sessions {
+		loaded = append(loaded, session.Track)
+	}
+	if len(loaded) != 3 || loaded[0] != "mugello" || loaded[2] != "silverstone" {
+		t.Errorf("loaded %q, want the 3 most recent sessions", loaded)
+	}
+}
+
+func TestFileStoreLoadMissing(t *testing.T) {
+	sessions, err := NewFileStore(filepath.Join(t.TempDir(), "history.jsonl")).Load(10)
+	if err != nil || len(sessions) != 0 {
+		t.Errorf("Load = %v, %v, want no sessions", sessions, err)
+	}
+}
@@ -0,0 +1,313 @@
+// Package supervisor runs the Assetto Corsa server process and applies a restart policy when it exits.
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"agones/metrics"
+	"agones/types"
+	"agones/utils"
+)
+
+// Policy defines what happens when the server process exits unexpectedly.
+type Policy string
+
+// Supported restart policies.
+const (
+	PolicyRestart  Policy = "restart"  // Restart the process with backoff, up to MaxRestarts times
+	PolicyShutdown Policy = "shutdown" // Mark the server unhealthy and shut down
+)
+
+// ParsePolicy validates a restart policy name.
+func ParsePolicy(name string) (Policy, error) {
+	switch p := Policy(name); p {
+	case PolicyRestart, PolicyShutdown:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown restart policy %q", name)
+	}
+}
+
+// Config configures the supervisor.
+type Config struct {
+	Policy      Policy        // Policy applied when the process exits
+	MaxRestarts int           // Maximum number of consecutive restarts before giving up
+	Backoff     time.Duration // Delay before the first restart, doubled on every attempt
+	MaxBackoff  time.Duration // Upper bound of the restart delay
+	ResetAfter  time.Duration // Run time after which the consecutive restart count is reset
+}
+
+// ExitStatus describes how the server process exited.
+type ExitStatus struct {
+	Code    int           // Exit code, -1 if the process was killed by a signal
+	Signal  string        // Name of the signal that killed the process, if any
+	Runtime time.Duration // How long the process ran
+	Err     error         // Error returned by Wait, if any
+}
+
+// String returns a human readable description of the exit status.
+func (e ExitStatus) String() string {
+	if e.Signal != "" {
+		return fmt.Sprintf("killed by signal %s after %v", e.Signal, e.Runtime.Round(time.Second))
+	}
+	return fmt.Sprintf("exited with code %d after %v", e.Code, e.Runtime.Round(time.Second))
+}
+
+// StopTimeout is the time given to the process to exit on SIGTERM before it is killed.
+const StopTimeout = 15 * time.Second
+
+// ErrGaveUp is returned by Run when the process exited and the policy does not allow a restart.
+var ErrGaveUp = errors.New("server process exited and will not be restarted")
+
+// Supervisor starts the server process, waits for it and restarts it according to its policy.
+type Supervisor struct {
+	config  Config
+	state   *types.ServerState
+	command func(ctx context.Context) *exec.Cmd // Builds a fresh command for every start
+
+	// OnExit is called after every exit of the process, before the policy is applied.
+	OnExit func(status ExitStatus)
+
+	mu               sync.Mutex
+	process          *os.Process // Running process, nil when not running
+	restartRequested bool        // Indicates the running process is stopped by Restart
+}
+
+// New creates a Supervisor using command to build the process for every start.
+func New(config Config, state *types.ServerState, command func(ctx context.Context) *exec.Cmd) *Supervisor {
+	return &Supervisor{
+		config:  config,
+		state:   state,
+		command: command,
+	}
+}
+
+// Run starts the process and supervises it until ctx is cancelled, the server shuts down,
+// or the policy gives up. Failed starts count as exits. It returns ErrGaveUp (wrapped) when
+// the policy gives up.
+func (sv *Supervisor) Run(ctx context.Context) error {
+	restarts := 0
+
+	for {
+		status, err := sv.runOnce(ctx)
+		outcome := status.String()
+		if err != nil {
+			// A process that failed to start is subject to the policy like one that exited
+			outcome = err.Error()
+		} else if sv.OnExit != nil {
+			sv.OnExit(status)
+		}
+
+		// Exits caused by our own shutdown are expected
+		if ctx.Err() != nil || sv.shuttingDown() {
+			utils.LogSDK("Server process %s during shutdown", outcome)
+			return nil
+		}
+
+		// Restarts on request do not count against the policy
+		if sv.takeRestartRequest() {
+			utils.LogSDK("Server process %s, restarting on request", outcome)
+			sv.setRestartPending(true)
+			continue
+		}
+
+		utils.LogError("Server process %s", outcome)
+
+		if sv.config.ResetAfter > 0 && status.Runtime >= sv.config.ResetAfter {
+			restarts = 0
+		}
+
+		if sv.config.Policy != PolicyRestart || restarts >= sv.config.MaxRestarts {
+			sv.markUnhealthy()
+			return fmt.Errorf("%w: %s (%d restarts)", ErrGaveUp, outcome, restarts)
+		}
+
+		restarts++
+		sv.setRestartPending(true)
+		delay := sv.backoff(restarts)
+		utils.LogSDK("Restarting server process in %v (attempt %d/%d)", delay, restarts, sv.config.MaxRestarts)
+		metrics.ServerRestartCounter.With(sv.labels()).Inc()
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+	}
+}
+
+// runOnce starts the process and waits for it to exit.
+func (sv *Supervisor) runOnce(ctx context.Context) (ExitStatus, error) {
+	cmd := sv.command(ctx)
+	// The process gets its own group so that stopping it also stops the processes it started,
+	// and a leftover child holding the output pipes cannot block Wait
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if cmd.Cancel != nil {
+		cmd.Cancel = func() error { return signalGroup(cmd.Process, syscall.SIGTERM) }
+	}
+	cmd.WaitDelay = StopTimeout
+	if err := cmd.Start(); err != nil {
+		return ExitStatus{}, fmt.Errorf("failed to start: %v", err)
+	}
+
+	started := time.Now()
+	metrics.ServerStartCounter.With(sv.labels()).Inc()
+	utils.LogSDK("Server process started with PID %d", cmd.Process.Pid)
+
+	sv.state.Lock()
+	sv.state.ProcessPID = cmd.Process.Pid
+	sv.state.RestartPending = false
+	sv.state.ProcessStarted = started
+	sv.state.UpdateLoopStarted = false
+	sv.state.Unlock()
+
+	sv.mu.Lock()
+	sv.process = cmd.Process
+	sv.mu.Unlock()
+
+	err := cmd.Wait()
+
+	sv.mu.Lock()
+	sv.process = nil
+	sv.mu.Unlock()
+	status := exitStatus(cmd, err)
+	status.Runtime = time.Since(started)
+
+	sv.recordExit(status)
+	return status, nil
+}
+
+// Restart stops the running process with SIGTERM, killing it if it does not exit in time,
+// and starts it again without applying the restart policy. If no process is running, the
+// next start happens as scheduled.
+func (sv *Supervisor) Restart() {
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+
+	if sv.process == nil {
+		return
+	}
+	sv.restartRequested = true
+	process := sv.process
+
+	utils.LogSDK("Stopping server process %d for restart", process.Pid)
+	if err := signalGroup(process, syscall.SIGTERM); err != nil {
+		utils.LogWarning("Failed to stop server process: %v", err)
+	}
+	time.AfterFunc(StopTimeout, func() {
+		sv.mu.Lock()
+		defer sv.mu.Unlock()
+		if sv.process == process {
+			utils.LogWarning("Server process %d did not stop in %v, killing it", process.Pid, StopTimeout)
+			signalGroup(process, syscall.SIGKILL)
+		}
+	})
+}
+
+// signalGroup sends sig to the process group led by process.
+func signalGroup(process *os.Process, sig syscall.Signal) error {
+	if err := syscall.Kill(-process.Pid, sig); err != nil {
+		if errors.Is(err, syscall.ESRCH) {
+			return os.ErrProcessDone
+		}
+		return err
+	}
+	return nil
+}
+
+// takeRestartRequest reports and clears a pending restart request.
+func (sv *Supervisor) takeRestartRequest() bool {
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+	requested := sv.restartRequested
+	sv.restartRequested = false
+	return requested
+}
+
+// recordExit clears the process related state and updates the exit metrics.
+// The players disconnected along with the process are reported by OnExit.
+func (sv *Supervisor) recordExit(status ExitStatus) {
+	sv.state.Lock()
+	sv.state.ProcessPID = 0
+	sv.state.LastExitCode = status.Code
+	sv.state.UpdateLoopStarted = false
+	sv.state.Unlock()
+
+	labels := sv.labels()
+	metrics.ServerLastExitCodeGauge.With(labels).Set(float64(status.Code))
+
+	exitLabels := sv.labels()
+	exitLabels["exit_code"] = strconv.Itoa(status.Code)
+	exitLabels["signal"] = status.Signal
+	metrics.ServerExitCounter.With(exitLabels).Inc()
+}
+
+// markUnhealthy flags the server as unhealthy so that health pings stop.
+func (sv *Supervisor) markUnhealthy() {
+	sv.state.Lock()
+	sv.state.Unhealthy = true
+	sv.state.RestartPending = false
+	sv.state.Unlock()
+}
+
+// setRestartPending records whether the process is waiting to be restarted, during which
+// the health pings continue.
+func (sv *Supervisor) setRestartPending(pending bool) {
+	sv.state.Lock()
+	sv.state.RestartPending = pending
+	sv.state.Unlock()
+}
+
+// shuttingDown reports whether the server is shutting down.
+func (sv *Supervisor) shuttingDown() bool {
+	sv.state.RLock()
+	defer sv.state.RUnlock()
+	return sv.state.ShuttingDown
+}
+
+// backoff returns the delay before the given restart attempt.
+func (sv *Supervisor) backoff(attempt int) time.Duration {
+	delay := sv.config.Backoff
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if sv.config.MaxBackoff > 0 && delay >= sv.config.MaxBackoff {
+			return sv.config.MaxBackoff
+		}
+	}
+	return delay
+}
+
+// labels returns the server labels for the supervisor metrics.
+func (sv *Supervisor) labels() prometheus.Labels {
+	sv.state.RLock()
+	defer sv.state.RUnlock()
+	return prometheus.Labels{
+		"server_id":   sv.state.ServerID,
+		"server_name": sv.state.ServerName,
+		"server_type": sv.state.ServerType,
+	}
+}
+
+// exitStatus extracts the exit code and signal from a finished command.
+func exitStatus(cmd *exec.Cmd, err error) ExitStatus {
+	status := ExitStatus{Code: -1, Err: err}
+	if cmd.ProcessState == nil {
+		return status
+	}
+
+	status.Code = cmd.ProcessState.ExitCode()
+	if ws, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		status.Signal = ws.Signal().String()
+	}
+	return status
+}
@@ -0,0 +1,124 @@
+package supervisor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"agones/types"
+)
+
+// syncBuffer is a bytes.Buffer safe for the concurrent writes of the output copy.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func TestRunGivesUp(t *testing.T) {
+	var starts int32
+	state := &types.ServerState{}
+	sv := New(Config{Policy: PolicyRestart, MaxRestarts: 2, Backoff: time.Millisecond}, state,
+		func(ctx context.Context) *exec.Cmd {
+			if atomic.AddInt32(&starts, 1) > 1 && !state.RestartPending {
+				t.Error("restart not flagged as pending during the backoff")
+			}
+			return exec.CommandContext(ctx, "sh", "-c", "exit 3")
+		})
+
+	var exits []ExitStatus
+	sv.OnExit = func(status ExitStatus) { exits = append(exits, status) }
+
+	err := sv.Run(context.Background())
+	if !errors.Is(err, ErrGaveUp) {
+		t.Fatalf("Run = %v, want ErrGaveUp", err)
+	}
+	if starts != 3 || len(exits) != 3 || exits[0].Code != 3 {
+		t.Errorf("starts = %d, exits = %+v, want 3 exits with code 3", starts, exits)
+	}
+	if !state.Unhealthy || state.RestartPending {
+		t.Errorf("Unhealthy = %v, RestartPending = %v after giving up", state.Unhealthy, state.RestartPending)
+	}
+}
+
+func TestRunRetriesFailedStarts(t *testing.T) {
+	tests := []struct {
+		policy Policy
+		starts int32
+	}{
+		{PolicyRestart, 3},
+		{PolicyShutdown, 1},
+	}
+	for _, tt := range tests {
+		var starts int32
+		state := &types.ServerState{}
+		sv := New(Config{Policy: tt.policy, MaxRestarts: 2, Backoff: time.Millisecond}, state,
+			func(ctx context.Context) *exec.Cmd {
+				atomic.AddInt32(&starts, 1)
+				return exec.CommandContext(ctx, "/nonexistent/start-server.sh")
+			})
+		exits := 0
+		sv.OnExit = func(ExitStatus) { exits++ }
+
+		err := sv.Run(context.Background())
+		if !errors.Is(err, ErrGaveUp) {
+			t.Fatalf("%s: Run = %v, want ErrGaveUp", tt.policy, err)
+		}
+		if starts != tt.starts || exits != 0 {
+			t.Errorf("%s: %d starts and %d exits, want %d failed starts", tt.policy, starts, exits, tt.starts)
+		}
+		if !state.Unhealthy {
+			t.Errorf("%s: not unhealthy after giving up", tt.policy)
+		}
+	}
+}
+
+func TestRunStopsProcessGroup(t *testing.T) {
+	state := &types.ServerState{}
+	started := make(chan struct{})
+	sv := New(Config{Policy: PolicyShutdown}, state, func(ctx context.Context) *exec.Cmd {
+		// The background child inherits the output pipe, as a server started by a script does
+		cmd := exec.CommandContext(ctx, "sh", "-c", "sleep 60 & echo started; wait")
+		cmd.Stdout = &syncBuffer{}
+		close(started)
+		return cmd
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- sv.Run(ctx) }()
+	<-started
+	time.Sleep(200 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run = %v, want nil on cancellation", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after cancellation")
+	}
+	if state.ProcessPID != 0 {
+		t.Errorf("ProcessPID = %d after exit", state.ProcessPID)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	sv := New(Config{Backoff: time.Second, MaxBackoff: 5 * time.Second}, &types.ServerState{}, nil)
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second} {
+		if got := sv.backoff(attempt); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+}
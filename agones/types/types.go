@@ -9,47 +9,124 @@ import (
 // ServerState represents the current state of the Assetto Corsa server.
 type ServerState struct {
 	sync.RWMutex
-	Ready            bool               // Indicates if the server is ready to accept connections
-	Players          int                // Current number of connected players
-	LastPing         time.Time          // Timestamp of the last successful health check
-	Allocated        bool               // Indicates if the server is currently allocated
-	ServerID         string             // Unique identifier of the server
-	ServerName       string             // Name of the server
-	ServerType       string             // Type of the server
-	SessionType      string             // Type of the current session
-	SessionStart     time.Time          // Start time of the session
-	SessionTimeLeft  int                // Time left in the session (seconds)
-	CurrentTrack     string             // Current track name
-	CurrentLayout    string             // Current track layout
-	TrackTemp        float64            // Track temperature
-	AirTemp          float64            // Air temperature
-	TrackGrip        float64            // Track grip level
-	ConnectedPlayers map[string]*Player // Map of connected players
-	ActiveCars       map[string]int     // Map of active cars
-	TickRate         float64            // Current tick rate
-	CurrentSession   *Session           // Current active session
-	ShuttingDown     bool               // Indicates if the server is shutting down
+	Ready             bool               // Indicates if the server is ready to accept connections
+	Players           int                // Current number of connected players
+	LastPing          time.Time          // Timestamp of the last successful health check
+	Allocated         bool               // Indicates if the server is currently allocated
+	ServerID          string             // Unique identifier of the server
+	ServerName        string             // Name of the server
+	ServerType        string             // Type of the server
+	SessionType       string             // Type of the current session
+	SessionStart      time.Time          // Start time of the session
+	SessionTimeLeft   int                // Time left in the session (seconds)
+	CurrentTrack      string             // Current track name
+	CurrentLayout     string             // Current track layout
+	TrackTemp         float64            // Track temperature
+	AirTemp           float64            // Air temperature
+	TrackGrip         float64            // Track grip level
+	ConnectedPlayers  map[string]*Player // Map of connected players
+	ActiveCars        map[string]int     // Map of active cars
+	TickRate          float64            // Current tick rate
+	MaxClients        int                // Maximum number of clients reported by the server
+	CarModels         []string           // Car models available on the server
+	CurrentSession    *Session           // Current active session
+	ShuttingDown      bool               // Indicates if the server is shutting down
+	Draining          bool               // Indicates if the server is draining before a shutdown
+	Unhealthy         bool               // Indicates the game process failed and cannot be recovered
+	ProcessPID        int                // PID of the running game process, 0 when not running
+	RestartPending    bool               // Indicates the game process exited and is restarted within the restart policy
+	LastExitCode      int                // Exit code of the last game process run
+	ProcessStarted    time.Time          // Start time of the running game process
+	LastOutput        time.Time          // Timestamp of the last line of game process output
+	UpdateLoopStarted bool               // Indicates if the game process update loop has started
+}
+
+// LogContext returns the server context attached to structured log events.
+// It returns false instead of blocking when the state is locked for writing.
+func (s *ServerState) LogContext() (LogEvent, bool) {
+	if !s.TryRLock() {
+		return LogEvent{}, false
+	}
+	defer s.RUnlock()
+
+	sessionType := SessionTypeUnknown
+	if s.CurrentSession != nil {
+		sessionType = s.CurrentSession.Type
+	}
+	return LogEvent{
+		ServerID:    s.ServerID,
+		ServerName:  s.ServerName,
+		Players:     s.Players,
+		SessionType: sessionType,
+	}, true
 }
 
 // Player represents a player connected to the server.
 type Player struct {
-	Name       string  // Player's name
-	SteamID    string  // Player's Steam ID
-	CarModel   string  // Car model used by the player
-	BestLap    int64   // Player's best lap time (ms)
-	LastLap    int64   // Player's latest lap time (ms)
-	Latency    int     // Player's latency (ms)
-	PacketLoss float64 // Player's packet loss percentage
+	Name       string  `json:"name"`        // Player's name
+	SteamID    string  `json:"steam_id"`    // Player's Steam ID
+	CarModel   string  `json:"car_model"`   // Car model used by the player
+	BestLap    int64   `json:"best_lap"`    // Player's best lap time (ms)
+	LastLap    int64   `json:"last_lap"`    // Player's latest lap time (ms)
+	Laps       int     `json:"laps"`        // Number of laps completed in the current session
+	Latency    int     `json:"latency"`     // Player's latency (ms)
+	PacketLoss float64 `json:"packet_loss"` // Player's packet loss percentage
+
+	ConnectedAt time.Time `json:"connected_at"` // Time the player connected
 }
 
 // Session represents a game session.
 type Session struct {
-	Type          string
-	StartTime     time.Time
-	EndTime       time.Time
-	Track         string
-	ID            string
-	RemainingTime string
+	Type          string        `json:"type"`
+	StartTime     time.Time     `json:"start_time"`
+	EndTime       time.Time     `json:"end_time"`
+	Track         string        `json:"track"`
+	Layout        string        `json:"layout,omitempty"` // Layout of the track, empty for a single layout track
+	Length        string        `json:"length,omitempty"` // Length as logged by the server: "10 laps", "15 minutes" or "Infinite"
+	ID            string        `json:"id"`
+	RemainingTime string        `json:"remaining_time,omitempty"`
+	Participants  []Participant `json:"participants"`
+	Laps          []Lap         `json:"laps,omitempty"`
+}
+
+// Participant represents a player who took part in a session.
+type Participant struct {
+	SteamID   string `json:"steam_id"`             // Player's Steam ID
+	Name      string `json:"name"`                 // Player's name
+	CarModel  string `json:"car_model"`            // Car model used by the player
+	Laps      int    `json:"laps"`                 // Number of completed laps
+	BestLap   int64  `json:"best_lap,omitempty"`   // Best lap time without cuts (ms)
+	LastLap   int64  `json:"last_lap,omitempty"`   // Latest lap time (ms)
+	TotalTime int64  `json:"total_time,omitempty"` // Sum of the lap times (ms)
+	Cuts      int    `json:"cuts,omitempty"`       // Number of cuts over all laps
+}
+
+// Lap represents a lap completed during a session.
+type Lap struct {
+	SteamID   string    `json:"steam_id"`  // Steam ID of the driver
+	Name      string    `json:"name"`      // Name of the driver
+	CarModel  string    `json:"car_model"` // Car model used for the lap
+	LapTime   int64     `json:"lap_time"`  // Lap time (ms)
+	Cuts      int       `json:"cuts"`      // Number of cuts during the lap
+	Timestamp time.Time `json:"timestamp"` // Time the lap was completed
+}
+
+// Clone returns a deep copy of the session.
+func (s *Session) Clone() *Session {
+	clone := *s
+	clone.Participants = append([]Participant(nil), s.Participants...)
+	clone.Laps = append([]Lap(nil), s.Laps...)
+	return &clone
+}
+
+// Participant returns the participant with the given Steam ID, nil if there is none.
+func (s *Session) Participant(steamID string) *Participant {
+	for i := range s.Participants {
+		if s.Participants[i].SteamID == steamID {
+			return &s.Participants[i]
+		}
+	}
+	return nil
 }
 
 // TrackConditions represents the conditions of the track.
@@ -79,14 +156,54 @@ const (
 
 // Config provides flexible configuration options for the server.
 type Config struct {
-	ServerScript    string        `json:"server_script"`     // Path to the server script
-	ServerArgs      string        `json:"server_args"`       // Arguments for the server script
-	ShutdownTimeout time.Duration `json:"shutdown_timeout"`  // Timeout for server shutdown
-	ReserveDuration time.Duration `json:"reserve_duration"`  // Duration to reserve the server
-	HealthCheckRate time.Duration `json:"health_check_rate"` // Rate for health checks
-	MetricsPort     int           `json:"metrics_port"`      // Port for exposing metrics
-	HealthPort      int           `json:"health_port"`       // Port for health checks
-	Debug           bool          `json:"debug"`             // Enable debug mode
+	ServerScript         string        `json:"server_script" yaml:"server_script"`                   // Path to the server script
+	ServerArgs           string        `json:"server_args" yaml:"server_args"`                       // Arguments for the server script
+	ShutdownTimeout      time.Duration `json:"shutdown_timeout" yaml:"shutdown_timeout"`             // Timeout for server shutdown
+	DrainTimeout         time.Duration `json:"drain_timeout" yaml:"drain_timeout"`                   // Maximum duration of the drain before a shutdown
+	TerminationGrace     time.Duration `json:"termination_grace" yaml:"termination_grace"`           // Kubernetes terminationGracePeriodSeconds of the pod
+	HealthCheckRate      time.Duration `json:"health_check_rate" yaml:"health_check_rate"`           // Rate for health checks
+	MetricsInterval      time.Duration `json:"metrics_interval" yaml:"metrics_interval"`             // Interval between GameServer metrics updates
+	MetricsPort          int           `json:"metrics_port" yaml:"metrics_port"`                     // Port for exposing metrics
+	HealthPort           int           `json:"health_port" yaml:"health_port"`                       // Port for health checks
+	AdminPort            int           `json:"admin_port" yaml:"admin_port"`                         // Port of the admin API, 0 to disable it
+	AdminToken           string        `json:"admin_token" yaml:"admin_token"`                       // Bearer token required by the admin API
+	RconPort             int           `json:"rcon_port" yaml:"rcon_port"`                           // Port of the server RCON listener, 0 if disabled
+	RconPassword         string        `json:"rcon_password" yaml:"rcon_password"`                   // Admin password of the server, authenticating RCON
+	Debug                bool          `json:"debug" yaml:"debug"`                                   // Enable debug mode
+	LogFormat            string        `json:"log_format" yaml:"log_format"`                         // Format of the wrapper logs (text or json)
+	InputFormat          string        `json:"input_format" yaml:"input_format"`                     // Format of the server output (text or clef)
+	RulesFile            string        `json:"rules_file" yaml:"rules_file"`                         // Path to the output rules overriding the built-in ones
+	RestartPolicy        string        `json:"restart_policy" yaml:"restart_policy"`                 // Action when the server process exits
+	MaxRestarts          int           `json:"max_restarts" yaml:"max_restarts"`                     // Maximum number of consecutive restarts
+	RestartBackoff       time.Duration `json:"restart_backoff" yaml:"restart_backoff"`               // Delay before the first restart
+	RestartMaxBackoff    time.Duration `json:"restart_max_backoff" yaml:"restart_max_backoff"`       // Maximum delay between restarts
+	LivenessOutputWindow time.Duration `json:"liveness_output_window" yaml:"liveness_output_window"` // Maximum time without server output
+	LivenessUpdateLoop   bool          `json:"liveness_update_loop" yaml:"liveness_update_loop"`     // Require the update loop for health pings
+	LivenessStartupGrace time.Duration `json:"liveness_startup_grace" yaml:"liveness_startup_grace"` // Grace period after a server start
+	LivenessInfoURL      string        `json:"liveness_info_url" yaml:"liveness_info_url"`           // URL of the server /INFO endpoint
+	ServerAPIURL         string        `json:"server_api_url" yaml:"server_api_url"`                 // Base URL of the server HTTP API
+	ServerAPIInterval    time.Duration `json:"server_api_interval" yaml:"server_api_interval"`       // Interval between server HTTP API polls
+	PlayerTracking       bool          `json:"player_tracking" yaml:"player_tracking"`               // Report players through Agones player tracking
+	EntryList            string        `json:"entry_list" yaml:"entry_list"`                         // Path to entry_list.ini, used for the player capacity
+	AgonesCounters       bool          `json:"agones_counters" yaml:"agones_counters"`               // Maintain the Agones players/free_slots counters and cars list
+	AllocationActions    string        `json:"allocation_actions" yaml:"allocation_actions"`         // Comma-separated actions applied on allocation
+	AllocationLimit      time.Duration `json:"allocation_limit" yaml:"allocation_limit"`             // Session limit of the session_timer allocation action
+	AllocationMetadata   string        `json:"allocation_metadata" yaml:"allocation_metadata"`       // File written by the metadata allocation action
+	IdleAction           string        `json:"idle_action" yaml:"idle_action"`                       // Action applied to an idle allocated server (ready or shutdown)
+	IdleNeverJoined      time.Duration `json:"idle_never_joined" yaml:"idle_never_joined"`           // Time after allocation without any player joining
+	IdleEmpty            time.Duration `json:"idle_empty" yaml:"idle_empty"`                         // Time without players after the last one left
+	SessionEndPolicy     string        `json:"session_end_policy" yaml:"session_end_policy"`         // Action when a session ends
+	SessionEndCount      int           `json:"session_end_count" yaml:"session_end_count"`           // Completed sessions for shutdown-after-n-sessions
+	SessionEndAfter      time.Duration `json:"session_end_after" yaml:"session_end_after"`           // Running time for shutdown-after-wall-clock
+	SessionStore         string        `json:"session_store" yaml:"session_store"`                   // JSON-lines file persisting the completed sessions
+	ResultsDir           string        `json:"results_dir" yaml:"results_dir"`                       // Directory the session results are written to
+	ResultsWebhook       string        `json:"results_webhook" yaml:"results_webhook"`               // URL the session results are posted to
+	ModerationWebhook    string        `json:"moderation_webhook" yaml:"moderation_webhook"`         // URL the moderation events are posted to
+	Webhooks             string        `json:"webhooks" yaml:"webhooks"`                             // Lifecycle and player event endpoints, separated by semicolons
+	WebhookSecret        string        `json:"webhook_secret" yaml:"webhook_secret"`                 // Key signing the webhook events
+	Schedule             string        `json:"schedule" yaml:"schedule"`                             // Scheduled jobs, separated by semicolons
+	ConfigTemplates      string        `json:"config_templates" yaml:"config_templates"`             // Directory of the server configuration templates
+	ConfigRenderDir      string        `json:"config_render_dir" yaml:"config_render_dir"`           // Directory the server configuration is rendered to
 }
 
 // LogEvent represents a structured log event with contextual information.
@@ -102,6 +219,27 @@ type LogEvent struct {
 	Error       string    `json:"error,omitempty"` // Error message, if any
 }
 
+// Moderation event types.
+const (
+	ModerationKick            = "kick"             // A player was kicked
+	ModerationBan             = "ban"              // A player was banned
+	ModerationBlacklistReject = "blacklist_reject" // A blacklisted player was refused
+	ModerationWhitelistReject = "whitelist_reject" // A player missing from the whitelist was refused
+	ModerationAuthFailure     = "auth_failure"     // A player failed the Steam authentication
+)
+
+// ModerationEvent represents a moderation action or refusal concerning a player.
+type ModerationEvent struct {
+	Timestamp  time.Time `json:"timestamp"`        // Time of the event
+	Type       string    `json:"type"`             // Type of event (see the Moderation constants)
+	ServerID   string    `json:"server_id"`        // ID of the server
+	ServerName string    `json:"server_name"`      // Name of the server
+	SteamID    string    `json:"steam_id"`         // Steam ID of the player, if known
+	Name       string    `json:"name"`             // Name of the player, if known
+	Reason     string    `json:"reason,omitempty"` // Reason given for the action
+	Actor      string    `json:"actor"`            // Admin who acted, "server" for automatic refusals
+}
+
 // GameServerSDK defines the interface for interacting with the game server.
 type GameServerSDK interface {
 	Health() error                                      // Perform a health check
@@ -43,8 +43,9 @@ func (cb *CircuitBreaker) RecordFailure() bool {
 // IsOpen checks if the circuit is open.
 // If the circuit is open and the timeout has expired since the last failure, it resets the circuit.
 func (cb *CircuitBreaker) IsOpen() bool {
-	cb.RLock()
-	defer cb.RUnlock()
+	// The write lock is needed for the reset: upgrading a read lock would deadlock
+	cb.Lock()
+	defer cb.Unlock()
 
 	if !cb.isOpen {
 		return false
@@ -52,10 +53,8 @@ func (cb *CircuitBreaker) IsOpen() bool {
 
 	// Auto-reset after timeout
 	if time.Since(cb.lastFailure) > cb.timeout {
-		cb.Lock()
 		cb.isOpen = false
 		cb.failures = 0
-		cb.Unlock()
 		return false
 	}
 	return true
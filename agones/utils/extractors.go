@@ -2,6 +2,9 @@
 package utils
 
 import (
+	"fmt"
+	"math"
+	"regexp"
 	"strconv"
 	"strings"
 
@@ -48,23 +51,37 @@ func ExtractSteamID(output string) string {
 	return ""
 }
 
-// ExtractCarModel extracts the car model from server output.
+// ExtractCarModel extracts the car model from server output, in the format
+// "Name (SteamID, SessionID (Model-Skin)) has connected".
 func ExtractCarModel(output string) string {
 	if start := strings.LastIndex(output, "("); start != -1 {
-		if end := strings.LastIndex(output, ")"); end != -1 && end > start {
-			carModel := output[start+1 : end]
+		if end := strings.Index(output[start:], ")"); end != -1 {
+			carModel := output[start+1 : start+end]
 			// Remove any additional info after the car model
 			if idx := strings.Index(carModel, ","); idx != -1 {
 				carModel = carModel[:idx]
 			}
+			// Remove the skin
+			if idx := strings.Index(carModel, "-"); idx != -1 {
+				carModel = carModel[:idx]
+			}
 			return strings.TrimSpace(carModel)
 		}
 	}
 	return ""
 }
 
-// ExtractSessionType extracts the session type from server output.
+// ExtractSessionType extracts the session type from server output. A session change line,
+// "Next session: Race - Length: 10 laps", is classified by the configured session name.
 func ExtractSessionType(output string) string {
+	if idx := strings.Index(output, "Next session:"); idx != -1 {
+		output = output[idx+len("Next session:"):]
+		if end := strings.Index(output, " - Length"); end != -1 {
+			output = output[:end]
+		}
+	}
+	output = strings.ToUpper(output)
+
 	if strings.Contains(output, "PRACTICE") {
 		return "practice"
 	}
@@ -77,6 +94,16 @@ func ExtractSessionType(output string) string {
 	return "unknown"
 }
 
+// ExtractSessionLength extracts the session length from a session change line,
+// "Next session: Race - Length: 10 laps". It is empty if the line has no length.
+func ExtractSessionLength(output string) string {
+	idx := strings.Index(output, " - Length:")
+	if idx == -1 {
+		return ""
+	}
+	return strings.TrimSpace(output[idx+len(" - Length:"):])
+}
+
 // ExtractTrackName extracts the track name from server output.
 func ExtractTrackName(output string) string {
 	if strings.Contains(output, "TRACK:") {
@@ -157,3 +184,111 @@ func ExtractAISlots(output string) map[string]int {
 
 	return slots
 }
+
+// ExtractLap extracts the driver name, number of cuts and lap time (ms) from a lap completion,
+// in the format "Lap completed by Name, 0 cuts, laptime 01:32.4560" (mm:ss.ffff).
+func ExtractLap(output string) (string, int, int64) {
+	idx := strings.Index(output, "Lap completed by")
+	if idx == -1 {
+		return "", 0, 0
+	}
+	output = output[idx+len("Lap completed by"):]
+
+	// The name may itself contain commas, so split from the end
+	timeIdx := strings.LastIndex(output, ", laptime")
+	if timeIdx == -1 {
+		return "", 0, 0
+	}
+	lapTime, err := ParseLapTime(output[timeIdx+len(", laptime"):])
+	if err != nil {
+		return "", 0, 0
+	}
+	output = output[:timeIdx]
+
+	cuts := 0
+	if cutsIdx := strings.LastIndex(output, ","); cutsIdx != -1 {
+		cutsStr := strings.TrimSuffix(strings.TrimSpace(output[cutsIdx+1:]), "cuts")
+		if n, err := strconv.Atoi(strings.TrimSpace(cutsStr)); err == nil {
+			cuts = n
+			output = output[:cutsIdx]
+		}
+	}
+	return strings.TrimSpace(output), cuts, lapTime
+}
+
+// ParseLapTime parses a lap time given either in milliseconds or as [hh:]mm:ss.fff.
+func ParseLapTime(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return ms, nil
+	}
+
+	parts := strings.Split(value, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid lap time %q", value)
+	}
+	seconds, err := strconv.ParseFloat(parts[len(parts)-1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid lap time %q", value)
+	}
+	for i, unit := range []float64{60, 3600}[:len(parts)-1] {
+		n, err := strconv.Atoi(parts[len(parts)-2-i])
+		if err != nil {
+			return 0, fmt.Errorf("invalid lap time %q", value)
+		}
+		seconds += float64(n) * unit
+	}
+	return int64(math.Round(seconds * 1000)), nil
+}
+
+// steamIDPattern matches a 64-bit Steam ID.
+var steamIDPattern = regexp.MustCompile(`\b7656\d{13}\b`)
+
+// ExtractModeration extracts the Steam ID, player name, reason and actor of a moderation line,
+// such as "Name was kicked. Reason: spamming" or "Steam authentication failed for Name (3):
+// Invalid ticket". Missing parts are returned empty.
+func ExtractModeration(output string) (steamID, name, reason, actor string) {
+	// Remove timestamp if present
+	if idx := strings.Index(output, "]"); idx != -1 {
+		output = strings.TrimSpace(output[idx+1:])
+	}
+
+	steamID = steamIDPattern.FindString(output)
+
+	if idx := strings.Index(strings.ToLower(output), "reason:"); idx != -1 {
+		reason = strings.TrimSpace(output[idx+len("reason:"):])
+		output = output[:idx]
+	}
+	if idx := strings.Index(output, " by "); idx != -1 {
+		actor = strings.Trim(strings.TrimSpace(output[idx+len(" by "):]), ".,")
+		output = output[:idx]
+	}
+
+	// Authentication failures name the player last, as in "authentication failed for Name (3):
+	// reason", the number being the session ID
+	if idx := strings.Index(output, " failed for "); idx != -1 {
+		output = output[idx+len(" failed for "):]
+		if idx := strings.Index(output, "): "); idx != -1 && reason == "" {
+			reason = strings.TrimSpace(output[idx+len("): "):])
+			output = output[:idx+1]
+		}
+	}
+
+	// Rejected handshakes only log the response sent, as in "Sending AuthFailedResponse (reason)"
+	if rest, ok := strings.CutPrefix(output, "Sending AuthFailedResponse ("); ok {
+		return steamID, "", strings.TrimSuffix(strings.TrimSpace(rest), ")"), actor
+	}
+
+	// The name comes first, before the Steam ID or the verb
+	end := len(output)
+	for _, sep := range []string{" (", " was ", " has ", " is ", " tried ", " failed "} {
+		if idx := strings.Index(output, sep); idx != -1 && idx < end {
+			end = idx
+		}
+	}
+	name = strings.TrimSpace(output[:end])
+	if name == steamID || strings.HasPrefix(name, "Steam ID") {
+		name = ""
+	}
+	return steamID, name, reason, actor
+}
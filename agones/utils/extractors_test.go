@@ -0,0 +1,73 @@
+package utils
+
+import "testing"
+
+func TestParseLapTime(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int64
+		err   bool
+	}{
+		{"01:32.4560", 92456, false},
+		{"00:59.9999", 60000, false},
+		{"10:00.0000", 600000, false},
+		{"1:02:03.5", 3723500, false},
+		{" 92456 ", 92456, false},
+		{"92.456", 0, true},
+		{"", 0, true},
+		{"xx:32.4560", 0, true},
+		{"01:32.4560.1", 0, true},
+		{"1:2:3:4", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLapTime(tt.value)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("ParseLapTime(%q) = %d, %v, want %d (error %v)", tt.value, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestExtractLap(t *testing.T) {
+	tests := []struct {
+		line    string
+		name    string
+		cuts    int
+		lapTime int64
+	}{
+		{"[12:02:00 INF] Lap completed by Bob, 0 cuts, laptime 01:32.4560", "Bob", 0, 92456},
+		{"[12:02:31 INF] Lap completed by Alice Smith, 3 cuts, laptime 01:45.0010", "Alice Smith", 3, 105001},
+		{"[12:03:00 INF] Lap completed by Smith, John, 1 cuts, laptime 02:00.5000", "Smith, John", 1, 120500},
+		{"Lap completed by Bob, 0 cuts, laptime 92456", "Bob", 0, 92456},
+		{"[12:04:00 INF] Lap completed by Bob, 0 cuts, laptime --:--", "", 0, 0},
+		{"[12:05:00 INF] Lap completed by Bob", "", 0, 0},
+		{"[12:06:00 INF] Bob (76561198000000001) has connected", "", 0, 0},
+	}
+	for _, tt := range tests {
+		name, cuts, lapTime := ExtractLap(tt.line)
+		if name != tt.name || cuts != tt.cuts || lapTime != tt.lapTime {
+			t.Errorf("ExtractLap(%q) = %q, %d, %d, want %q, %d, %d", tt.line, name, cuts, lapTime, tt.name, tt.cuts, tt.lapTime)
+		}
+	}
+}
+
+func TestExtractModeration(t *testing.T) {
+	// Lines as logged by AssettoServer
+	tests := []struct {
+		line                         string
+		steamID, name, reason, actor string
+	}{
+		{"[12:07:00 INF] Bob was kicked. Reason: No reason given.", "", "Bob", "No reason given.", ""},
+		{"[12:07:00 INF] Bob Smith was banned. Reason: vote banned", "", "Bob Smith", "vote banned", ""},
+		{"[12:07:00 INF] Bob was banned after reloading blacklist", "", "Bob", "", ""},
+		{"[12:07:00 INF] Bob (76561198000000001) is using Steam family sharing and game owner 76561198000000002 is blacklisted", "76561198000000001", "Bob", "", ""},
+		{"[12:07:00 DBG] Sending AuthFailedResponse (You are not whitelisted on this server)", "", "", "You are not whitelisted on this server", ""},
+		{"[12:07:00 WRN] Steam authentication failed for Bob (3): Invalid ticket", "", "Bob", "Invalid ticket", ""},
+	}
+	for _, tt := range tests {
+		steamID, name, reason, actor := ExtractModeration(tt.line)
+		if steamID != tt.steamID || name != tt.name || reason != tt.reason || actor != tt.actor {
+			t.Errorf("ExtractModeration(%q) = %q, %q, %q, %q, want %q, %q, %q, %q",
+				tt.line, steamID, name, reason, actor, tt.steamID, tt.name, tt.reason, tt.actor)
+		}
+	}
+}
@@ -1,9 +1,14 @@
 package utils
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
+	"os"
+	"sync"
 	"time"
+
+	"agones/types"
 )
 
 func init() {
@@ -19,32 +24,134 @@ const (
 	LogFormatERR = "[%s ERR] %s"
 )
 
+// Log output formats selectable with SetLogOutput.
+const (
+	LogOutputText = "text" // Human readable lines, e.g. "[15:04:05 SDK] message"
+	LogOutputJSON = "json" // One types.LogEvent JSON object per line
+)
+
+// LogContextProvider returns the server context attached to JSON log events.
+// It returns false when the context is temporarily unavailable.
+type LogContextProvider func() (types.LogEvent, bool)
+
+var (
+	logMu          sync.Mutex
+	logOutput      = LogOutputText
+	logDebug       bool
+	logProvider    LogContextProvider
+	logLastContext types.LogEvent // Last context returned by the provider
+)
+
+// SetLogOutput selects the log output format.
+func SetLogOutput(output string) error {
+	switch output {
+	case LogOutputText, LogOutputJSON:
+	default:
+		return fmt.Errorf("unknown log output %q", output)
+	}
+
+	logMu.Lock()
+	defer logMu.Unlock()
+	logOutput = output
+	return nil
+}
+
+// SetDebug enables or disables the debug logs.
+func SetDebug(enabled bool) {
+	logMu.Lock()
+	defer logMu.Unlock()
+	logDebug = enabled
+}
+
+// SetLogContextProvider sets the provider of the server context attached to JSON log events.
+func SetLogContextProvider(provider LogContextProvider) {
+	logMu.Lock()
+	defer logMu.Unlock()
+	logProvider = provider
+}
+
 func LogSDK(format string, v ...interface{}) {
-	timestamp := time.Now().Format("15:04:05")
-	message := fmt.Sprintf(format, v...)
-	log.Printf(LogFormatSDK, timestamp, message)
+	logf("SDK", "", LogFormatSDK, format, v...)
 }
 
 func LogInfo(format string, v ...interface{}) {
-	timestamp := time.Now().Format("15:04:05")
-	message := fmt.Sprintf(format, v...)
-	log.Printf(LogFormatINF, timestamp, message)
+	logf("INFO", "", LogFormatINF, format, v...)
 }
 
 func LogDebug(format string, v ...interface{}) {
-	timestamp := time.Now().Format("15:04:05")
-	message := fmt.Sprintf(format, v...)
-	log.Printf(LogFormatDBG, timestamp, message)
+	logMu.Lock()
+	enabled := logDebug
+	logMu.Unlock()
+	if !enabled {
+		return
+	}
+	logf("DEBUG", "", LogFormatDBG, format, v...)
 }
 
 func LogWarning(format string, v ...interface{}) {
-	timestamp := time.Now().Format("15:04:05")
-	message := fmt.Sprintf(format, v...)
-	log.Printf(LogFormatWRN, timestamp, message)
+	logf("WARNING", "", LogFormatWRN, format, v...)
 }
 
 func LogError(format string, v ...interface{}) {
-	timestamp := time.Now().Format("15:04:05")
+	logf("ERROR", "", LogFormatERR, format, v...)
+}
+
+// LogEvent logs a message for a named event (e.g. SESSION_CHANGE) along with the server context.
+func LogEvent(event string, format string, v ...interface{}) {
+	logf("SDK", event, LogFormatSDK, format, v...)
+}
+
+// logf writes a log message in the selected output format.
+func logf(level, event, textFormat, format string, v ...interface{}) {
+	now := time.Now()
 	message := fmt.Sprintf(format, v...)
-	log.Printf(LogFormatERR, timestamp, message)
+
+	logMu.Lock()
+	defer logMu.Unlock()
+
+	if logOutput != LogOutputJSON {
+		if event != "" {
+			ctx := logContext()
+			message = fmt.Sprintf("[%s] %s | Server: %s | Players: %d | Session: %s",
+				event, message, ctx.ServerName, ctx.Players, ctx.SessionType)
+		}
+		log.Printf(textFormat, now.Format("15:04:05"), message)
+		return
+	}
+
+	record := logContext()
+	record.Timestamp = now
+	record.Level = level
+	record.Event = event
+	record.Message = message
+	record.Error = errorArg(v)
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to encode log event: %v\n", err)
+		return
+	}
+	log.Print(string(data))
+}
+
+// logContext returns the current server context, or the last known one when the
+// provider cannot supply it. Must be called with logMu held.
+func logContext() types.LogEvent {
+	if logProvider == nil {
+		return types.LogEvent{}
+	}
+	if ctx, ok := logProvider(); ok {
+		logLastContext = ctx
+	}
+	return logLastContext
+}
+
+// errorArg returns the message of the last error among the log arguments, if any.
+func errorArg(v []interface{}) string {
+	for i := len(v) - 1; i >= 0; i-- {
+		if err, ok := v[i].(error); ok && err != nil {
+			return err.Error()
+		}
+	}
+	return ""
 }
@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"agones/types"
+)
+
+// captureLogs redirects the logs to a buffer in the given output format until the test ends.
+func captureLogs(t *testing.T, output string) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	if err := SetLogOutput(output); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		log.SetOutput(os.Stderr)
+		SetLogOutput(LogOutputText)
+		SetDebug(false)
+		SetLogContextProvider(nil)
+	})
+	return &buf
+}
+
+func TestLogJSON(t *testing.T) {
+	buf := captureLogs(t, LogOutputJSON)
+	available := true
+	SetLogContextProvider(func() (types.LogEvent, bool) {
+		return types.LogEvent{ServerID: "gs-1", ServerName: "Monza", Players: 3, SessionType: types.SessionTypeRace}, available
+	})
+
+	before := time.Now()
+	LogEvent("SESSION_CHANGE", "Session changed to %s", "race")
+	available = false
+	LogError("Failed to notify Agones: %v", errors.New("connection refused"))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("%d log lines, want 2:\n%s", len(lines), buf)
+	}
+	var event, failure types.LogEvent
+	if err := json.Unmarshal([]byte(lines[0]), &event); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &failure); err != nil {
+		t.Fatal(err)
+	}
+
+	want := types.LogEvent{
+		Timestamp:   event.Timestamp,
+		Level:       "SDK",
+		Event:       "SESSION_CHANGE",
+		ServerID:    "gs-1",
+		ServerName:  "Monza",
+		Message:     "Session changed to race",
+		Players:     3,
+		SessionType: types.SessionTypeRace,
+	}
+	if event != want || event.Timestamp.Before(before.Truncate(time.Second)) {
+		t.Errorf("event %+v, want %+v", event, want)
+	}
+	// The last known context is kept while the provider cannot supply it
+	if failure.Level != "ERROR" || failure.Error != "connection refused" || failure.ServerID != "gs-1" || failure.Players != 3 {
+		t.Errorf("error %+v, want the error message and the last context", failure)
+	}
+}
+
+func TestLogDebug(t *testing.T) {
+	buf := captureLogs(t, LogOutputText)
+
+	LogDebug("hidden %d", 1)
+	if buf.Len() != 0 {
+		t.Errorf("debug log written while disabled: %q", buf)
+	}
+
+	SetDebug(true)
+	LogDebug("shown %d", 2)
+	if got := buf.String(); !strings.Contains(got, " DBG] shown 2") {
+		t.Errorf("debug log %q, want the message at the DBG level", got)
+	}
+}
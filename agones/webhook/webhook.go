@@ -0,0 +1,376 @@
+// Package webhook notifies external systems of the server lifecycle and player events by
+// posting signed JSON events to the configured endpoints.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"agones/metrics"
+	"agones/session"
+	"agones/types"
+	"agones/utils"
+)
+
+// Supported event types.
+const (
+	EventReady         = "ready"          // The GameServer was marked Ready
+	EventPlayerJoin    = "player_join"    // A player connected
+	EventSessionChange = "session_change" // A new session started
+	EventShutdown      = "shutdown"       // The server is shutting down
+)
+
+// eventTypes lists the supported event types.
+var eventTypes = []string{EventReady, EventPlayerJoin, EventSessionChange, EventShutdown}
+
+// Delivery settings.
+const (
+	queueSize      = 100              // Events waiting per endpoint before new ones are dropped
+	maxAttempts    = 4                // Attempts per event, including the first one
+	breakerFails   = 5                // Failed deliveries in a row opening the circuit of an endpoint
+	breakerTimeout = time.Minute      // Time the circuit of an endpoint stays open
+	requestTimeout = 10 * time.Second // Timeout of a single attempt
+)
+
+// initialBackoff is the delay before the first retry, doubled on every retry.
+var initialBackoff = time.Second
+
+// Headers set on every delivery.
+const (
+	HeaderEvent     = "X-Webhook-Event"     // Type of the event
+	HeaderSignature = "X-Webhook-Signature" // "sha256=" followed by the hex HMAC-SHA256 of the body
+)
+
+// Event is an event posted to the endpoints. It carries the fields of the structured log
+// events, the event type being in Event, along with the player or session concerned.
+type Event struct {
+	types.LogEvent
+	Player  *types.Player  `json:"player,omitempty"`  // Player of a player_join event
+	Session *types.Session `json:"session,omitempty"` // Session of a session_change event
+}
+
+// Endpoint is a URL the events are posted to.
+type Endpoint struct {
+	URL    string
+	Events []string // Event types posted to the endpoint, all of them if empty
+}
+
+// accepts reports whether the endpoint receives events of the given type.
+func (e Endpoint) accepts(eventType string) bool {
+	if len(e.Events) == 0 {
+		return true
+	}
+	for _, t := range e.Events {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseEndpoints parses a list of endpoints separated by semicolons or line breaks, each made
+// of a URL optionally followed by a comma-separated list of event types, e.g.
+// "https://example.com/hook ready,shutdown; https://example.org/hook".
+func ParseEndpoints(spec string) ([]Endpoint, error) {
+	var endpoints []Endpoint
+	for _, entry := range strings.FieldsFunc(spec, func(r rune) bool { return r == ';' || r == '\n' }) {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("webhook %q must be a URL followed by a list of events", strings.TrimSpace(entry))
+		}
+
+		u, err := url.Parse(fields[0])
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid webhook URL %q", fields[0])
+		}
+		endpoint := Endpoint{URL: fields[0]}
+		if len(fields) == 2 {
+			for _, t := range strings.Split(fields[1], ",") {
+				if !supported(t) {
+					return nil, fmt.Errorf("unknown webhook event %q (expected one of %s)", t, strings.Join(eventTypes, ", "))
+				}
+				endpoint.Events = append(endpoint.Events, t)
+			}
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints, nil
+}
+
+// supported reports whether eventType is a supported event type.
+func supported(eventType string) bool {
+	for _, t := range eventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Config configures a Dispatcher.
+type Config struct {
+	Endpoints []Endpoint
+	Secret    string // Key of the HMAC signature, empty to leave the deliveries unsigned
+}
+
+// Dispatcher posts the events to the endpoints. Each endpoint has its own queue, worker and
+// circuit breaker, so that a failing endpoint neither delays nor loses the events of the others.
+// The methods of a nil Dispatcher do nothing.
+type Dispatcher struct {
+	state    *types.ServerState
+	sessions *session.SessionManager // Source of the session_change events, may be nil
+	secret   []byte
+	client   *http.Client
+	targets  []*target
+
+	mu          sync.RWMutex
+	stopped     bool
+	cancel      context.CancelFunc // Aborts the deliveries in progress
+	unsubscribe func()
+	wg          sync.WaitGroup
+}
+
+// target is an endpoint along with its delivery state.
+type target struct {
+	Endpoint
+	queue   chan Event
+	breaker *utils.CircuitBreaker
+}
+
+// NewDispatcher creates a Dispatcher. Session changes are published from the transitions of sessions.
+func NewDispatcher(state *types.ServerState, sessions *session.SessionManager, config Config) *Dispatcher {
+	d := &Dispatcher{
+		state:    state,
+		sessions: sessions,
+		secret:   []byte(config.Secret),
+		client:   &http.Client{Timeout: requestTimeout},
+	}
+	for _, endpoint := range config.Endpoints {
+		d.targets = append(d.targets, &target{
+			Endpoint: endpoint,
+			queue:    make(chan Event, queueSize),
+			breaker:  utils.NewCircuitBreaker(breakerFails, breakerTimeout),
+		})
+	}
+	return d
+}
+
+// Start starts the delivery workers and the publication of the session changes.
+// It is the Start of a lifecycle.Component.
+func (d *Dispatcher) Start(_ context.Context) error {
+	if d == nil {
+		return nil
+	}
+
+	// Deliveries outlive the wrapper context so that the shutdown event is delivered
+	ctx, cancel := context.WithCancel(context.Background())
+	d.cancel = cancel
+	for _, t := range d.targets {
+		d.wg.Add(1)
+		go func(t *target) {
+			defer d.wg.Done()
+			for event := range t.queue {
+				d.deliver(ctx, t, event)
+			}
+		}(t)
+	}
+
+	if d.sessions != nil {
+		var transitions <-chan session.SessionTransition
+		transitions, d.unsubscribe = d.sessions.Subscribe(10)
+		go func() {
+			for transition := range transitions {
+				if transition.Started == nil {
+					continue
+				}
+				d.Publish(Event{
+					LogEvent: types.LogEvent{
+						Event:   EventSessionChange,
+						Message: fmt.Sprintf("Session changed from %s to %s", transition.From, transition.To),
+					},
+					Session: transition.Started,
+				})
+			}
+		}()
+	}
+	return nil
+}
+
+// Stop stops accepting events and waits for the queued ones to be delivered. When ctx is done
+// first, the deliveries in progress are aborted. It is the Stop of a lifecycle.Component.
+func (d *Dispatcher) Stop(ctx context.Context) error {
+	if d == nil || d.cancel == nil {
+		return nil
+	}
+	if d.unsubscribe != nil {
+		d.unsubscribe()
+	}
+
+	d.mu.Lock()
+	if d.stopped {
+		d.mu.Unlock()
+		return nil
+	}
+	d.stopped = true
+	for _, t := range d.targets {
+		close(t.queue)
+	}
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	defer d.cancel()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		d.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// Publish fills in the server context of event and queues it for the endpoints accepting its type.
+// It never blocks: an event is dropped for an endpoint whose queue is full.
+// The state lock must not be held by the caller.
+func (d *Dispatcher) Publish(event Event) {
+	if d == nil {
+		return
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	if event.Level == "" {
+		event.Level = "INFO"
+	}
+
+	d.state.RLock()
+	event.ServerID = d.state.ServerID
+	event.ServerName = d.state.ServerName
+	event.Players = d.state.Players
+	event.SessionType = d.state.SessionType
+	d.state.RUnlock()
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.stopped {
+		utils.LogWarning("Webhook dispatcher stopped, %s event dropped", event.Event)
+		return
+	}
+	for _, t := range d.targets {
+		if !t.accepts(event.Event) {
+			continue
+		}
+		select {
+		case t.queue <- event:
+		default:
+			utils.LogWarning("Webhook queue of %s full, %s event dropped", t.URL, event.Event)
+			d.record(event.Event, "dropped")
+		}
+	}
+}
+
+// deliver posts an event to the endpoint of t, retrying with an exponential backoff.
+// Events are dropped without any attempt while the circuit of the endpoint is open.
+func (d *Dispatcher) deliver(ctx context.Context, t *target, event Event) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		utils.LogError("Failed to encode %s webhook event: %v", event.Event, err)
+		return
+	}
+
+	backoff := initialBackoff
+	for attempt := 1; ; attempt++ {
+		if t.breaker.IsOpen() {
+			utils.LogWarning("Webhook circuit of %s open, %s event dropped", t.URL, event.Event)
+			d.record(event.Event, "circuit_open")
+			return
+		}
+
+		retry, err := d.post(ctx, t.URL, event.Event, data)
+		if err == nil {
+			t.breaker.Reset()
+			d.record(event.Event, "success")
+			return
+		}
+		if !retry || attempt == maxAttempts || ctx.Err() != nil {
+			utils.LogWarning("Failed to post %s event to %s after %d attempts: %v", event.Event, t.URL, attempt, err)
+			if t.breaker.RecordFailure() {
+				utils.LogWarning("Webhook circuit of %s opened for %v", t.URL, breakerTimeout)
+			}
+			d.record(event.Event, "failure")
+			return
+		}
+
+		utils.LogDebug("Failed to post %s event to %s, retrying in %v: %v", event.Event, t.URL, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+		}
+		backoff *= 2
+	}
+}
+
+// post posts a signed event to endpointURL. It reports whether a failure is worth retrying:
+// client errors other than 408 and 429 are not.
+func (d *Dispatcher) post(ctx context.Context, endpointURL, eventType string, data []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpointURL, bytes.NewReader(data))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, eventType)
+	if len(d.secret) > 0 {
+		req.Header.Set(HeaderSignature, "sha256="+Sign(d.secret, data))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests
+		return retry, fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return false, nil
+}
+
+// Sign returns the hex HMAC-SHA256 of body with secret, as set in the signature header.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// record counts a delivery of an event of the given type.
+func (d *Dispatcher) record(eventType, outcome string) {
+	d.state.RLock()
+	labels := prometheus.Labels{
+		"server_id":   d.state.ServerID,
+		"server_name": d.state.ServerName,
+		"server_type": d.state.ServerType,
+		"event":       eventType,
+		"outcome":     outcome,
+	}
+	d.state.RUnlock()
+	metrics.WebhookDeliveriesCounter.With(labels).Inc()
+}
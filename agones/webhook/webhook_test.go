@@ -0,0 +1,231 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"agones/types"
+)
+
+// delivery is a request received by a receiver.
+type delivery struct {
+	event     string
+	signature string
+	body      []byte
+}
+
+// receiver is a webhook endpoint answering every request with status.
+type receiver struct {
+	*httptest.Server
+	status int
+
+	mu         sync.Mutex
+	deliveries []delivery
+}
+
+func newReceiver(t *testing.T, status int) *receiver {
+	r := &receiver{status: status}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		r.deliveries = append(r.deliveries, delivery{
+			event:     req.Header.Get(HeaderEvent),
+			signature: req.Header.Get(HeaderSignature),
+			body:      body,
+		})
+		r.mu.Unlock()
+		w.WriteHeader(r.status)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *receiver) received() []delivery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]delivery(nil), r.deliveries...)
+}
+
+func (r *receiver) events() []string {
+	var events []string
+	for _, d := range r.received() {
+		events = append(events, d.event)
+	}
+	return events
+}
+
+func newTestDispatcher(config Config) *Dispatcher {
+	return NewDispatcher(&types.ServerState{ServerID: "gs-1"}, nil, config)
+}
+
+func publish(d *Dispatcher, events ...string) {
+	for _, event := range events {
+		d.Publish(Event{LogEvent: types.LogEvent{Event: event}})
+	}
+}
+
+func stop(t *testing.T, d *Dispatcher) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := d.Stop(ctx); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+}
+
+func TestDeliverySignature(t *testing.T) {
+	r := newReceiver(t, http.StatusOK)
+	d := newTestDispatcher(Config{Endpoints: []Endpoint{{URL: r.URL}}, Secret: "s3cret"})
+	d.Start(context.Background())
+	publish(d, EventReady)
+	stop(t, d)
+
+	received := r.received()
+	if len(received) != 1 {
+		t.Fatalf("%d deliveries, want 1", len(received))
+	}
+	if want := "sha256=" + Sign([]byte("s3cret"), received[0].body); received[0].signature != want {
+		t.Errorf("signature = %q, want %q", received[0].signature, want)
+	}
+	var event Event
+	if err := json.Unmarshal(received[0].body, &event); err != nil {
+		t.Fatal(err)
+	}
+	if received[0].event != EventReady || event.Event != EventReady || event.ServerID != "gs-1" {
+		t.Errorf("delivered %s header, %+v", received[0].event, event.LogEvent)
+	}
+}
+
+func TestEndpointEvents(t *testing.T) {
+	filtered, all := newReceiver(t, http.StatusOK), newReceiver(t, http.StatusNoContent)
+	d := newTestDispatcher(Config{Endpoints: []Endpoint{
+		{URL: filtered.URL, Events: []string{EventReady, EventShutdown}},
+		{URL: all.URL},
+	}})
+	d.Start(context.Background())
+	publish(d, EventReady, EventPlayerJoin, EventShutdown)
+	stop(t, d)
+
+	if got, want := filtered.events(), []string{EventReady, EventShutdown}; !reflect.DeepEqual(got, want) {
+		t.Errorf("filtered endpoint received %q, want %q", got, want)
+	}
+	if got, want := all.events(), []string{EventReady, EventPlayerJoin, EventShutdown}; !reflect.DeepEqual(got, want) {
+		t.Errorf("endpoint received %q, want %q", got, want)
+	}
+	if got := all.received()[0].signature; got != "" {
+		t.Errorf("unsigned delivery has signature %q", got)
+	}
+}
+
+func TestDeliveryRetries(t *testing.T) {
+	defer func(backoff time.Duration) { initialBackoff = backoff }(initialBackoff)
+	initialBackoff = time.Millisecond
+
+	tests := []struct {
+		status   int
+		attempts int
+	}{
+		{http.StatusInternalServerError, maxAttempts},
+		{http.StatusServiceUnavailable, maxAttempts},
+		{http.StatusTooManyRequests, maxAttempts},
+		{http.StatusRequestTimeout, maxAttempts},
+		{http.StatusBadRequest, 1},
+		{http.StatusUnauthorized, 1},
+		{http.StatusNotFound, 1},
+	}
+	for _, tt := range tests {
+		r := newReceiver(t, tt.status)
+		d := newTestDispatcher(Config{Endpoints: []Endpoint{{URL: r.URL}}})
+		d.Start(context.Background())
+		publish(d, EventReady)
+		stop(t, d)
+
+		if got := len(r.received()); got != tt.attempts {
+			t.Errorf("status %d: %d attempts, want %d", tt.status, got, tt.attempts)
+		}
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	failing, healthy := newReceiver(t, http.StatusBadRequest), newReceiver(t, http.StatusOK)
+	d := newTestDispatcher(Config{Endpoints: []Endpoint{{URL: failing.URL}, {URL: healthy.URL}}})
+	d.Start(context.Background())
+	for i := 0; i < breakerFails+3; i++ {
+		publish(d, EventPlayerJoin)
+	}
+	stop(t, d)
+
+	if got := len(failing.received()); got != breakerFails {
+		t.Errorf("failing endpoint received %d deliveries, want %d before its circuit opened", got, breakerFails)
+	}
+	if got := len(healthy.received()); got != breakerFails+3 {
+		t.Errorf("healthy endpoint received %d deliveries, want %d", got, breakerFails+3)
+	}
+}
+
+func TestQueueFull(t *testing.T) {
+	r := newReceiver(t, http.StatusOK)
+	d := newTestDispatcher(Config{Endpoints: []Endpoint{{URL: r.URL}}})
+
+	// Nothing is delivered before Start, so the queue fills up
+	for i := 0; i < queueSize+10; i++ {
+		publish(d, EventPlayerJoin)
+	}
+	if got := len(d.targets[0].queue); got != queueSize {
+		t.Fatalf("%d queued events, want %d", got, queueSize)
+	}
+	d.Start(context.Background())
+	stop(t, d)
+
+	if got := len(r.received()); got != queueSize {
+		t.Errorf("%d deliveries, want %d", got, queueSize)
+	}
+}
+
+func TestStopDrainsQueue(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	delivered := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		<-release
+		mu.Lock()
+		delivered++
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	d := newTestDispatcher(Config{Endpoints: []Endpoint{{URL: server.URL}}})
+	d.Start(context.Background())
+	publish(d, EventReady, EventPlayerJoin, EventShutdown)
+
+	stopped := make(chan error)
+	go func() { stopped <- d.Stop(context.Background()) }()
+	select {
+	case err := <-stopped:
+		t.Fatalf("Stop returned %v before the queue was delivered", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	if err := <-stopped; err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if delivered != 3 {
+		t.Errorf("%d events delivered when Stop returned, want 3", delivered)
+	}
+
+	// Events published after Stop are dropped
+	publish(d, EventReady)
+	if got := len(d.targets[0].queue); got != 0 {
+		t.Errorf("%d events queued after Stop", got)
+	}
+}
@@ -26,6 +26,12 @@ cd /app/AssettoServer || exit 1
 echo "Copying config from /shared-config to /app/AssettoServer..."
 cp -rfv /shared-config/* . || echo "Warning: Could not copy config files"
 
+# Overlay the configuration rendered by the wrapper from the allocation metadata
+if [ -n "$AC_RENDERED_CONFIG" ] && [ -d "$AC_RENDERED_CONFIG" ]; then
+    echo "Copying rendered config from $AC_RENDERED_CONFIG to /app/AssettoServer..."
+    cp -rfv "$AC_RENDERED_CONFIG"/. . || echo "Warning: Could not copy rendered config files"
+fi
+
 echo "Setting proper permissions for all files..."
 find . -type f -not -name "steamclient.so" -exec chmod 644 {} \;
 find . -type d -exec chmod 755 {} \;
@@ -33,6 +39,6 @@ find . -type d -exec chmod 755 {} \;
 # Make sure the server executable is executable
 chmod +x ./AssettoServer
 
-# Start Assetto Corsa Server
+# Start Assetto Corsa Server, replacing the shell so that it receives the wrapper signals
 echo "Starting Assetto Corsa Server..."
-./AssettoServer --plugins-from-workdir
\ No newline at end of file
+exec ./AssettoServer --plugins-from-workdir
\ No newline at end of file